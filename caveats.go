@@ -0,0 +1,119 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
+)
+
+// CaveatMerger combines the caveats attached by individual approvers into
+// the set that will be baked into the final discharge macaroon, rejecting
+// the merge if the approvers disagree in a way that cannot be reconciled.
+type CaveatMerger interface {
+	Merge(caveats []checkers.Caveat) ([]checkers.Caveat, error)
+}
+
+// timeBeforeCondition is the bakery checkers condition name for an
+// upper-bound deadline caveat (see checkers.TimeBeforeCaveat). Two
+// approvers independently attaching a deadline is the ordinary case, not a
+// conflict, so UnionCaveatMerger special-cases it below instead of treating
+// differing arguments as contradictory.
+const timeBeforeCondition = "time-before"
+
+// UnionCaveatMerger is the default CaveatMerger. It unions all approvers'
+// caveats, rejecting the merge if two caveats share a condition but
+// disagree on the argument — except time-before caveats, which are merged
+// by keeping the earliest (most restrictive) deadline.
+type UnionCaveatMerger struct{}
+
+// Merge implements CaveatMerger.
+func (UnionCaveatMerger) Merge(caveats []checkers.Caveat) ([]checkers.Caveat, error) {
+	seen := make(map[string]checkers.Caveat)
+	var order []string
+	for _, cav := range caveats {
+		cond, arg := splitCondition(cav.Condition)
+		prev, ok := seen[cond]
+		if !ok {
+			seen[cond] = cav
+			order = append(order, cond)
+			continue
+		}
+		_, prevArg := splitCondition(prev.Condition)
+		if prevArg == arg {
+			continue
+		}
+		if cond == timeBeforeCondition {
+			earliest, err := earlierTimeBefore(prevArg, arg)
+			if err != nil {
+				return nil, errgo.Mask(err, errgo.Any)
+			}
+			seen[cond] = checkers.Caveat{Location: cav.Location, Condition: timeBeforeCondition + " " + earliest}
+			continue
+		}
+		return nil, errgo.Newf("contradictory caveats for condition %q: %q vs %q", cond, prevArg, arg)
+	}
+	merged := make([]checkers.Caveat, len(order))
+	for i, cond := range order {
+		merged[i] = seen[cond]
+	}
+	return merged, nil
+}
+
+// earlierTimeBefore parses a and b as the RFC3339 timestamps used by
+// checkers.TimeBeforeCaveat, and returns whichever is earlier, i.e. the
+// more restrictive deadline.
+func earlierTimeBefore(a, b string) (string, error) {
+	at, err := time.Parse(time.RFC3339Nano, a)
+	if err != nil {
+		return "", errgo.Notef(err, "invalid time-before argument %q", a)
+	}
+	bt, err := time.Parse(time.RFC3339Nano, b)
+	if err != nil {
+		return "", errgo.Notef(err, "invalid time-before argument %q", b)
+	}
+	if at.Before(bt) {
+		return a, nil
+	}
+	return b, nil
+}
+
+// splitCondition splits a caveat condition into its name and argument, e.g.
+// "time-before 2020-01-01T00:00:00Z" becomes ("time-before",
+// "2020-01-01T00:00:00Z").
+func splitCondition(condition string) (string, string) {
+	parts := strings.SplitN(condition, " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// caveatChecker implements bakery.ThirdPartyChecker, returning a fixed set
+// of caveats regardless of the caveat condition being discharged. It is used
+// to bake the quorum's merged approver caveats into the discharge macaroon.
+type caveatChecker struct {
+	caveats []checkers.Caveat
+}
+
+// CheckThirdPartyCaveat implements bakery.ThirdPartyChecker.
+func (c caveatChecker) CheckThirdPartyCaveat(caveatID, caveat string) ([]checkers.Caveat, error) {
+	return c.caveats, nil
+}
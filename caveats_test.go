@@ -0,0 +1,59 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+import (
+	gc "gopkg.in/check.v1"
+	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
+
+	"github.com/cmars/quorum"
+)
+
+type CaveatsSuite struct{}
+
+var _ = gc.Suite(&CaveatsSuite{})
+
+func (s *CaveatsSuite) TestUnion(c *gc.C) {
+	merger := quorum.UnionCaveatMerger{}
+	merged, err := merger.Merge([]checkers.Caveat{
+		{Condition: "time-before 2020-01-01T00:00:00Z"},
+		{Condition: "client-ip 10.0.0.1"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(merged, gc.HasLen, 2)
+}
+
+func (s *CaveatsSuite) TestContradiction(c *gc.C) {
+	merger := quorum.UnionCaveatMerger{}
+	_, err := merger.Merge([]checkers.Caveat{
+		{Condition: "client-ip 10.0.0.1"},
+		{Condition: "client-ip 10.0.0.2"},
+	})
+	c.Assert(err, gc.ErrorMatches, `contradictory caveats for condition "client-ip": .*`)
+}
+
+func (s *CaveatsSuite) TestTimeBeforeTakesEarliest(c *gc.C) {
+	merger := quorum.UnionCaveatMerger{}
+	merged, err := merger.Merge([]checkers.Caveat{
+		{Condition: "time-before 2021-01-01T00:00:00Z"},
+		{Condition: "time-before 2020-01-01T00:00:00Z"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(merged, gc.DeepEquals, []checkers.Caveat{
+		{Condition: "time-before 2020-01-01T00:00:00Z"},
+	})
+}
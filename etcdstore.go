@@ -0,0 +1,423 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gopkg.in/errgo.v1"
+	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
+)
+
+// etcdStorage is a Storage implementation backed by etcd, so that elections
+// survive restarts and can be shared across Service replicas behind a load
+// balancer. Votes are applied with a compare-and-swap on the ballot key's
+// mod revision, so concurrent votes from different replicas cannot
+// double-count or race the approval threshold.
+type etcdStorage struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// NewEtcdStorage returns a Storage implementation backed by client, storing
+// elections and ballots under <prefix>/elections/<id> and
+// <prefix>/ballots/<id> respectively.
+func NewEtcdStorage(client *clientv3.Client, prefix string) Storage {
+	return &etcdStorage{client: client, prefix: prefix}
+}
+
+func (s *etcdStorage) electionKey(id string) string {
+	return s.prefix + "/elections/" + id
+}
+
+func (s *etcdStorage) ballotKey(id string) string {
+	return s.prefix + "/ballots/" + id
+}
+
+// Add implements the Storage interface.
+func (s *etcdStorage) Add(election Election, ballots []Ballot) error {
+	ops := make([]clientv3.Op, 0, len(ballots)+1)
+
+	electionJSON, err := json.Marshal(election)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	ops = append(ops, clientv3.OpPut(s.electionKey(election.ID), string(electionJSON)))
+
+	for _, ballot := range ballots {
+		ballotJSON, err := json.Marshal(ballot)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		ops = append(ops, clientv3.OpPut(s.ballotKey(ballot.ID), string(ballotJSON)))
+	}
+
+	_, err = s.client.Txn(context.Background()).Then(ops...).Commit()
+	return errgo.Mask(err)
+}
+
+// Approve implements the Storage interface.
+func (s *etcdStorage) Approve(id string, sig Signature, caveats []checkers.Caveat) error {
+	return s.vote(id, func(ballot *Ballot) {
+		ballot.Signature = &sig
+		ballot.Caveats = caveats
+	}, func(election *Election) {
+		election.NApprovals++
+	})
+}
+
+// Deny implements the Storage interface.
+func (s *etcdStorage) Deny(id string, sig Signature) error {
+	return s.vote(id, func(ballot *Ballot) {
+		ballot.Signature = &sig
+	}, func(election *Election) {
+		election.NDenials++
+	})
+}
+
+// vote applies updateBallot and updateElection to the named ballot and its
+// election with a compare-and-swap loop keyed on the ballot's mod revision,
+// retrying if another replica's vote lands first.
+func (s *etcdStorage) vote(id string, updateBallot func(*Ballot), updateElection func(*Election)) error {
+	for {
+		ballotKey := s.ballotKey(id)
+		getResp, err := s.client.Get(context.Background(), ballotKey)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if len(getResp.Kvs) == 0 {
+			return ErrNotFound
+		}
+		kv := getResp.Kvs[0]
+
+		var ballot Ballot
+		if err := json.Unmarshal(kv.Value, &ballot); err != nil {
+			return errgo.Mask(err)
+		}
+		if ballot.Used {
+			return ErrNotFound
+		}
+		ballot.Used = true
+		updateBallot(&ballot)
+		ballotJSON, err := json.Marshal(ballot)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+
+		txnResp, err := s.client.Txn(context.Background()).
+			If(clientv3.Compare(clientv3.ModRevision(ballotKey), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(ballotKey, string(ballotJSON))).
+			Commit()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if !txnResp.Succeeded {
+			continue
+		}
+
+		err = s.updateElectionCAS(ballot.Election, updateElection)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		return nil
+	}
+}
+
+func (s *etcdStorage) updateElectionCAS(id string, update func(*Election)) error {
+	for {
+		key := s.electionKey(id)
+		getResp, err := s.client.Get(context.Background(), key)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if len(getResp.Kvs) == 0 {
+			return ErrNotFound
+		}
+		kv := getResp.Kvs[0]
+
+		var election Election
+		if err := json.Unmarshal(kv.Value, &election); err != nil {
+			return errgo.Mask(err)
+		}
+		update(&election)
+		electionJSON, err := json.Marshal(election)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+
+		txnResp, err := s.client.Txn(context.Background()).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(key, string(electionJSON))).
+			Commit()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if !txnResp.Succeeded {
+			continue
+		}
+		return nil
+	}
+}
+
+// Election implements the Storage interface.
+func (s *etcdStorage) Election(id string) (Election, error) {
+	var fail Election
+	resp, err := s.client.Get(context.Background(), s.electionKey(id))
+	if err != nil {
+		return fail, errgo.Mask(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return fail, ErrNotFound
+	}
+	var election Election
+	if err := json.Unmarshal(resp.Kvs[0].Value, &election); err != nil {
+		return fail, errgo.Mask(err)
+	}
+	return election, nil
+}
+
+// Ballot implements the Storage interface.
+func (s *etcdStorage) Ballot(id string) (Ballot, error) {
+	var fail Ballot
+	resp, err := s.client.Get(context.Background(), s.ballotKey(id))
+	if err != nil {
+		return fail, errgo.Mask(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return fail, ErrNotFound
+	}
+	var ballot Ballot
+	if err := json.Unmarshal(resp.Kvs[0].Value, &ballot); err != nil {
+		return fail, errgo.Mask(err)
+	}
+	return ballot, nil
+}
+
+// Ballots implements the Storage interface.
+func (s *etcdStorage) Ballots(electionID string) ([]Ballot, error) {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/ballots/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	var ballots []Ballot
+	for _, kv := range resp.Kvs {
+		var ballot Ballot
+		if err := json.Unmarshal(kv.Value, &ballot); err != nil {
+			return nil, errgo.Mask(err)
+		}
+		if ballot.Election == electionID {
+			ballots = append(ballots, ballot)
+		}
+	}
+	return ballots, nil
+}
+
+// SetDeliveryStatus implements the Storage interface, applying the update
+// with a compare-and-swap loop keyed on the ballot's mod revision.
+func (s *etcdStorage) SetDeliveryStatus(id string, status DeliveryStatus) error {
+	key := s.ballotKey(id)
+	for {
+		getResp, err := s.client.Get(context.Background(), key)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if len(getResp.Kvs) == 0 {
+			return ErrNotFound
+		}
+		kv := getResp.Kvs[0]
+
+		var ballot Ballot
+		if err := json.Unmarshal(kv.Value, &ballot); err != nil {
+			return errgo.Mask(err)
+		}
+		ballot.DeliveryStatus = status
+		ballotJSON, err := json.Marshal(ballot)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+
+		txnResp, err := s.client.Txn(context.Background()).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(key, string(ballotJSON))).
+			Commit()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if !txnResp.Succeeded {
+			continue
+		}
+		return nil
+	}
+}
+
+// Caveats implements the Storage interface.
+func (s *etcdStorage) Caveats(electionID string) ([]checkers.Caveat, error) {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/ballots/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	var all []checkers.Caveat
+	for _, kv := range resp.Kvs {
+		var ballot Ballot
+		if err := json.Unmarshal(kv.Value, &ballot); err != nil {
+			return nil, errgo.Mask(err)
+		}
+		if ballot.Election == electionID && ballot.Used {
+			all = append(all, ballot.Caveats...)
+		}
+	}
+	return all, nil
+}
+
+// Watch implements the Storage interface using etcd's native watch API, so
+// the wait handler blocks on real state changes rather than polling. The
+// watch stream, and the channel it feeds, are closed once ctx is done.
+func (s *etcdStorage) Watch(ctx context.Context, electionID string) <-chan Election {
+	ch := make(chan Election, 1)
+	watchCh := s.client.Watch(ctx, s.electionKey(electionID))
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			for _, ev := range resp.Events {
+				var election Election
+				if err := json.Unmarshal(ev.Kv.Value, &election); err != nil {
+					continue
+				}
+				select {
+				case ch <- election:
+				default:
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// ReapExpired implements the Storage interface.
+func (s *etcdStorage) ReapExpired(now time.Time) error {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/elections/", clientv3.WithPrefix())
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, kv := range resp.Kvs {
+		var election Election
+		if err := json.Unmarshal(kv.Value, &election); err != nil {
+			return errgo.Mask(err)
+		}
+		if election.Expired || election.Deadline.IsZero() || election.Result() != ElectionPending {
+			continue
+		}
+		if election.Deadline.After(now) {
+			continue
+		}
+		err := s.updateElectionCAS(election.ID, func(e *Election) {
+			e.Expired = true
+			e.NDenials++
+		})
+		if err != nil && errgo.Cause(err) != ErrNotFound {
+			return errgo.Mask(err)
+		}
+	}
+	return nil
+}
+
+// CloseDenied implements the Storage interface.
+func (s *etcdStorage) CloseDenied() error {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/elections/", clientv3.WithPrefix())
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, kv := range resp.Kvs {
+		var election Election
+		if err := json.Unmarshal(kv.Value, &election); err != nil {
+			return errgo.Mask(err)
+		}
+		if election.Result() != ElectionDenied || !election.ClosedAt.IsZero() {
+			continue
+		}
+		if err := s.Close(election.ID); err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	return nil
+}
+
+// Close implements the Storage interface. It only sets ClosedAt, via the
+// same compare-and-swap loop updateElectionCAS uses elsewhere; the election
+// and its ballots (including their Signatures) stay queryable for audit
+// until a later PurgeClosed call deletes them.
+func (s *etcdStorage) Close(id string) error {
+	err := s.updateElectionCAS(id, func(e *Election) {
+		if e.ClosedAt.IsZero() {
+			e.ClosedAt = time.Now()
+		}
+	})
+	if errgo.Cause(err) == ErrNotFound {
+		return nil
+	}
+	return errgo.Mask(err)
+}
+
+// PurgeClosed implements the Storage interface.
+func (s *etcdStorage) PurgeClosed(before time.Time) error {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/elections/", clientv3.WithPrefix())
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	var purge []string
+	for _, kv := range resp.Kvs {
+		var election Election
+		if err := json.Unmarshal(kv.Value, &election); err != nil {
+			return errgo.Mask(err)
+		}
+		if election.ClosedAt.IsZero() || election.ClosedAt.After(before) {
+			continue
+		}
+		purge = append(purge, election.ID)
+	}
+	if len(purge) == 0 {
+		return nil
+	}
+
+	ballotsResp, err := s.client.Get(context.Background(), s.prefix+"/ballots/", clientv3.WithPrefix())
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	purging := make(map[string]bool, len(purge))
+	for _, id := range purge {
+		purging[id] = true
+	}
+
+	ops := make([]clientv3.Op, 0, len(purge))
+	for _, id := range purge {
+		ops = append(ops, clientv3.OpDelete(s.electionKey(id)))
+	}
+	for _, kv := range ballotsResp.Kvs {
+		var ballot Ballot
+		if err := json.Unmarshal(kv.Value, &ballot); err != nil {
+			return errgo.Mask(err)
+		}
+		if purging[ballot.Election] {
+			ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+		}
+	}
+	_, err = s.client.Txn(context.Background()).Then(ops...).Commit()
+	return errgo.Mask(err)
+}
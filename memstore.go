@@ -17,13 +17,18 @@
 package quorum
 
 import (
+	"context"
 	"sync"
+	"time"
+
+	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
 )
 
 type memStorage struct {
 	mu        sync.Mutex
 	elections map[string]Election
 	ballots   map[string]Ballot
+	watchers  map[string][]chan Election
 }
 
 // NewMemStorage returns a new ephemeral in-memory Storage implementation.
@@ -31,6 +36,7 @@ func NewMemStorage() *memStorage {
 	return &memStorage{
 		elections: map[string]Election{},
 		ballots:   map[string]Ballot{},
+		watchers:  map[string][]chan Election{},
 	}
 }
 
@@ -46,14 +52,17 @@ func (s *memStorage) Add(election Election, ballots []Ballot) error {
 }
 
 // Approve implements the Storage interface.
-func (s *memStorage) Approve(id string) error {
+func (s *memStorage) Approve(id string, sig Signature, caveats []checkers.Caveat) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	ballot, ok := s.ballots[id]
-	if !ok {
+	if !ok || ballot.Used {
 		return ErrNotFound
 	}
-	delete(s.ballots, id)
+	ballot.Used = true
+	ballot.Signature = &sig
+	ballot.Caveats = caveats
+	s.ballots[id] = ballot
 
 	election, ok := s.elections[ballot.Election]
 	if !ok {
@@ -61,18 +70,21 @@ func (s *memStorage) Approve(id string) error {
 	}
 	election.NApprovals++
 	s.elections[ballot.Election] = election
+	s.notifyLocked(ballot.Election)
 	return nil
 }
 
 // Deny implements the Storage interface.
-func (s *memStorage) Deny(id string) error {
+func (s *memStorage) Deny(id string, sig Signature) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	ballot, ok := s.ballots[id]
-	if !ok {
+	if !ok || ballot.Used {
 		return ErrNotFound
 	}
-	delete(s.ballots, id)
+	ballot.Used = true
+	ballot.Signature = &sig
+	s.ballots[id] = ballot
 
 	election, ok := s.elections[ballot.Election]
 	if !ok {
@@ -80,6 +92,7 @@ func (s *memStorage) Deny(id string) error {
 	}
 	election.NDenials++
 	s.elections[ballot.Election] = election
+	s.notifyLocked(ballot.Election)
 	return nil
 }
 
@@ -95,8 +108,175 @@ func (s *memStorage) Election(id string) (Election, error) {
 	return election, nil
 }
 
+// Ballot implements the Storage interface. The returned Ballot retains its
+// Signature after it has been voted on, so an auditor can later verify the
+// outcome of the election.
+func (s *memStorage) Ballot(id string) (Ballot, error) {
+	var fail Ballot
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ballot, ok := s.ballots[id]
+	if !ok {
+		return fail, ErrNotFound
+	}
+	return ballot, nil
+}
+
+// Ballots implements the Storage interface.
+func (s *memStorage) Ballots(electionID string) ([]Ballot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ballots []Ballot
+	for _, ballot := range s.ballots {
+		if ballot.Election == electionID {
+			ballots = append(ballots, ballot)
+		}
+	}
+	return ballots, nil
+}
+
+// SetDeliveryStatus implements the Storage interface.
+func (s *memStorage) SetDeliveryStatus(id string, status DeliveryStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ballot, ok := s.ballots[id]
+	if !ok {
+		return ErrNotFound
+	}
+	ballot.DeliveryStatus = status
+	s.ballots[id] = ballot
+	return nil
+}
+
+// Caveats implements the Storage interface.
+func (s *memStorage) Caveats(electionID string) ([]checkers.Caveat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var caveats []checkers.Caveat
+	for _, ballot := range s.ballots {
+		if ballot.Election != electionID {
+			continue
+		}
+		caveats = append(caveats, ballot.Caveats...)
+	}
+	return caveats, nil
+}
+
+// Watch implements the Storage interface. The returned channel receives the
+// election's state whenever it changes, and is closed once ctx is done or
+// the election is closed, whichever comes first.
+func (s *memStorage) Watch(ctx context.Context, electionID string) <-chan Election {
+	s.mu.Lock()
+	ch := make(chan Election, 1)
+	s.watchers[electionID] = append(s.watchers[electionID], ch)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unwatch(electionID, ch)
+	}()
+	return ch
+}
+
+// unwatch removes and closes ch from electionID's watcher list, if it's
+// still registered there (Close may already have removed and closed it).
+func (s *memStorage) unwatch(electionID string, ch chan Election) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	watchers := s.watchers[electionID]
+	for i, w := range watchers {
+		if w == ch {
+			s.watchers[electionID] = append(watchers[:i], watchers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// notifyLocked pushes the current state of electionID to its watchers. The
+// caller must hold s.mu.
+func (s *memStorage) notifyLocked(electionID string) {
+	election, ok := s.elections[electionID]
+	if !ok {
+		return
+	}
+	for _, ch := range s.watchers[electionID] {
+		select {
+		case ch <- election:
+		default:
+		}
+	}
+}
+
+// ReapExpired implements the Storage interface.
+func (s *memStorage) ReapExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, election := range s.elections {
+		if election.Deadline.IsZero() || election.Expired {
+			continue
+		}
+		if election.Result() != ElectionPending {
+			continue
+		}
+		if !election.Deadline.After(now) {
+			election.Expired = true
+			election.NDenials++
+			s.elections[id] = election
+			s.notifyLocked(id)
+		}
+	}
+	return nil
+}
+
+// CloseDenied implements the Storage interface.
+func (s *memStorage) CloseDenied() error {
+	s.mu.Lock()
+	var denied []string
+	for id, election := range s.elections {
+		if election.Result() == ElectionDenied && election.ClosedAt.IsZero() {
+			denied = append(denied, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range denied {
+		if err := s.Close(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close implements the Storage interface.
 func (s *memStorage) Close(id string) error {
-	delete(s.elections, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if election, ok := s.elections[id]; ok && election.ClosedAt.IsZero() {
+		election.ClosedAt = time.Now()
+		s.elections[id] = election
+	}
+	for _, ch := range s.watchers[id] {
+		close(ch)
+	}
+	delete(s.watchers, id)
+	return nil
+}
+
+// PurgeClosed implements the Storage interface.
+func (s *memStorage) PurgeClosed(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, election := range s.elections {
+		if election.ClosedAt.IsZero() || election.ClosedAt.After(before) {
+			continue
+		}
+		delete(s.elections, id)
+		for ballotID, ballot := range s.ballots {
+			if ballot.Election == id {
+				delete(s.ballots, ballotID)
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"net/url"
+
+	"gopkg.in/errgo.v1"
+)
+
+// MultiSender is a Sender that fans out to one of several transport-specific
+// Senders, chosen by the scheme of the recipient URI (e.g. "mailto:",
+// "slack:", "https:"). This lets a single election's participants be
+// reached over whichever transport they registered with.
+type MultiSender struct {
+	senders map[string]Sender
+}
+
+// NewMultiSender returns a Sender that dispatches to senders, keyed by the
+// URI scheme each one is registered to handle.
+func NewMultiSender(senders map[string]Sender) *MultiSender {
+	return &MultiSender{senders: senders}
+}
+
+// ValidateRecipient implements Sender by delegating to the Sender registered
+// for recipient's URI scheme.
+func (m *MultiSender) ValidateRecipient(recipient string) error {
+	sender, err := m.senderFor(recipient)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	return sender.ValidateRecipient(recipient)
+}
+
+// Send implements Sender by delegating to the Sender registered for the
+// ballot recipient's URI scheme.
+func (m *MultiSender) Send(ballot Ballot) error {
+	sender, err := m.senderFor(ballot.Recipient)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	return sender.Send(ballot)
+}
+
+func (m *MultiSender) senderFor(recipient string) (Sender, error) {
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return nil, errgo.Notef(err, "invalid recipient %q", recipient)
+	}
+	sender, ok := m.senders[u.Scheme]
+	if !ok {
+		return nil, errgo.Newf("no sender registered for scheme %q", u.Scheme)
+	}
+	return sender, nil
+}
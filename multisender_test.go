@@ -0,0 +1,66 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+import (
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+)
+
+type MultiSenderSuite struct{}
+
+var _ = gc.Suite(&MultiSenderSuite{})
+
+type stubSender struct {
+	validated []string
+	sent      []quorum.Ballot
+	err       error
+}
+
+func (s *stubSender) ValidateRecipient(recipient string) error {
+	s.validated = append(s.validated, recipient)
+	return s.err
+}
+
+func (s *stubSender) Send(ballot quorum.Ballot) error {
+	s.sent = append(s.sent, ballot)
+	return s.err
+}
+
+func (s *MultiSenderSuite) TestDispatchByScheme(c *gc.C) {
+	mailSender := &stubSender{}
+	slackSender := &stubSender{}
+	multi := quorum.NewMultiSender(map[string]quorum.Sender{
+		"mailto": mailSender,
+		"slack":  slackSender,
+	})
+
+	err := multi.ValidateRecipient("mailto:alice@example.com")
+	c.Assert(err, gc.IsNil)
+	c.Assert(mailSender.validated, gc.DeepEquals, []string{"mailto:alice@example.com"})
+
+	err = multi.Send(quorum.Ballot{Recipient: "slack:#approvals"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(slackSender.sent, gc.HasLen, 1)
+}
+
+func (s *MultiSenderSuite) TestNoSenderForScheme(c *gc.C) {
+	multi := quorum.NewMultiSender(map[string]quorum.Sender{})
+	err := multi.ValidateRecipient("https://example.com/hook")
+	c.Assert(err, gc.ErrorMatches, `no sender registered for scheme "https"`)
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+
+	"gopkg.in/errgo.v1"
+)
+
+// OPAPolicyEngine enforces policy by shelling out to the "opa eval" CLI
+// against a Rego policy, passing the Policy as its input document. The
+// query is expected to evaluate to an object with an "allow" boolean and
+// an optional "reason" string, e.g.:
+//
+//	package quorum
+//
+//	decision = {"allow": false, "reason": "..."} {
+//		...
+//	}
+type OPAPolicyEngine struct {
+	// OPAPath is the path to the opa binary. Defaults to "opa" on $PATH.
+	OPAPath string
+	// PolicyPath is the Rego source file or bundle directory to evaluate.
+	PolicyPath string
+	// Query is the Rego expression to evaluate, e.g. "data.quorum.decision".
+	Query string
+}
+
+// opaEvalOutput is the subset of "opa eval --format json" output this
+// engine understands.
+type opaEvalOutput struct {
+	Result []struct {
+		Expressions []struct {
+			Value struct {
+				Allow  bool   `json:"allow"`
+				Reason string `json:"reason"`
+			} `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// CheckPolicy implements PolicyEngine by evaluating e.Query against
+// e.PolicyPath, with policy passed as the evaluator's input document.
+func (e *OPAPolicyEngine) CheckPolicy(policy Policy) error {
+	opaPath := e.OPAPath
+	if opaPath == "" {
+		opaPath = "opa"
+	}
+	input, err := json.Marshal(policy)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	cmd := exec.Command(opaPath, "eval", "--format", "json", "--data", e.PolicyPath, "--stdin-input", e.Query)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errgo.Notef(err, "opa eval failed: %s", stderr.String())
+	}
+
+	var output opaEvalOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return errgo.Notef(err, "invalid opa eval output")
+	}
+	if len(output.Result) == 0 || len(output.Result[0].Expressions) == 0 {
+		return &PolicyViolation{Rule: e.Query, Reason: "rego policy produced no result"}
+	}
+
+	decision := output.Result[0].Expressions[0].Value
+	if !decision.Allow {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by rego policy"
+		}
+		return &PolicyViolation{Rule: e.Query, Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+)
+
+// PolicyEngine decides whether a caveat-supplied Policy is permitted to run
+// as an election, before any ballots are created for it. This keeps a
+// client that can mint a third-party caveat from naming arbitrary
+// participants: the engine can, for example, require that every
+// participant belongs to an approved group, or that certain participants
+// never appear together.
+type PolicyEngine interface {
+	// CheckPolicy returns a non-nil error, typically a *PolicyViolation, if
+	// policy breaks one of the engine's rules.
+	CheckPolicy(policy Policy) error
+}
+
+// PolicyViolation is returned by a PolicyEngine when a Policy breaks one of
+// its rules, naming the rule so callers can surface it in, for example, the
+// interaction-required response.
+type PolicyViolation struct {
+	Rule   string
+	Reason string
+}
+
+// Error implements error.
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("policy rule %q violated: %s", v.Rule, v.Reason)
+}
+
+// GroupResolver resolves a group identifier (such as "oncall@") to the
+// participant identifiers currently belonging to it, for PolicyRules that
+// reference group membership.
+type GroupResolver interface {
+	ResolveGroup(name string) ([]string, error)
+}
+
+// StaticGroupResolver is a GroupResolver backed by a fixed map of group name
+// to member identifiers.
+type StaticGroupResolver map[string][]string
+
+// ResolveGroup implements GroupResolver.
+func (r StaticGroupResolver) ResolveGroup(name string) ([]string, error) {
+	members, ok := r[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return members, nil
+}
+
+// PolicyRules is the rule DSL enforced by a StaticPolicyEngine, typically
+// loaded from a JSON config file. Each of Allow, Deny, RequireOneOf and
+// MutuallyExclusive names participant identifiers (a Participant's
+// Recipient) using one of four pattern forms:
+//
+//	alice@example.com      an exact match
+//	oncall-*@example.com   a glob, matched with path.Match
+//	regex:^oncall-.*$      a regular expression
+//	group:oncall@          group membership, resolved by a GroupResolver
+type PolicyRules struct {
+	// Allow, if non-empty, requires every participant to match at least
+	// one of these patterns.
+	Allow []string `json:"allow,omitempty"`
+	// Deny rejects the Policy if any participant matches one of these
+	// patterns, regardless of Allow.
+	Deny []string `json:"deny,omitempty"`
+	// MinApprovalsRequired rejects any Policy whose NApprovalsRequired
+	// falls below this floor.
+	MinApprovalsRequired int `json:"min_approvals_required,omitempty"`
+	// RequireOneOf rejects the Policy unless, for every pattern group in
+	// this list, at least one participant matches one of its patterns.
+	// For example, [["group:oncall@"]] requires at least one participant
+	// from the oncall@ group.
+	RequireOneOf [][]string `json:"require_one_of,omitempty"`
+	// MutuallyExclusive rejects the Policy if participants match more than
+	// one pattern from the same group in this list. For example,
+	// [["alice@example.com", "bob@example.com"]] forbids naming both
+	// alice and her manager bob as participants.
+	MutuallyExclusive [][]string `json:"mutually_exclusive,omitempty"`
+}
+
+// Check evaluates rules against policy, resolving any group: patterns with
+// groups, and returns a *PolicyViolation naming the first rule broken.
+func (rules PolicyRules) Check(policy Policy, groups GroupResolver) error {
+	identifiers := make([]string, len(policy.Participants))
+	for i, participant := range policy.Participants {
+		identifiers[i] = participant.Recipient
+	}
+
+	if rules.MinApprovalsRequired > 0 && policy.NApprovalsRequired < rules.MinApprovalsRequired {
+		return &PolicyViolation{
+			Rule: "min_approvals_required",
+			Reason: fmt.Sprintf("policy requires only %d approvals, but at least %d are mandated",
+				policy.NApprovalsRequired, rules.MinApprovalsRequired),
+		}
+	}
+
+	for _, identifier := range identifiers {
+		if len(rules.Deny) > 0 {
+			denied, err := matchAny(groups, rules.Deny, identifier)
+			if err != nil {
+				return errgo.Mask(err, errgo.Any)
+			}
+			if denied {
+				return &PolicyViolation{Rule: "deny", Reason: fmt.Sprintf("%q is on the deny list", identifier)}
+			}
+		}
+		if len(rules.Allow) > 0 {
+			allowed, err := matchAny(groups, rules.Allow, identifier)
+			if err != nil {
+				return errgo.Mask(err, errgo.Any)
+			}
+			if !allowed {
+				return &PolicyViolation{Rule: "allow", Reason: fmt.Sprintf("%q is not on the allow list", identifier)}
+			}
+		}
+	}
+
+	for _, required := range rules.RequireOneOf {
+		satisfied := false
+		for _, identifier := range identifiers {
+			ok, err := matchAny(groups, required, identifier)
+			if err != nil {
+				return errgo.Mask(err, errgo.Any)
+			}
+			if ok {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			return &PolicyViolation{
+				Rule:   "require_one_of",
+				Reason: fmt.Sprintf("no participant matches any of %v", required),
+			}
+		}
+	}
+
+	for _, exclusive := range rules.MutuallyExclusive {
+		var present []string
+		for _, pattern := range exclusive {
+			for _, identifier := range identifiers {
+				ok, err := matchPattern(groups, pattern, identifier)
+				if err != nil {
+					return errgo.Mask(err, errgo.Any)
+				}
+				if ok {
+					present = append(present, identifier)
+					break
+				}
+			}
+		}
+		if len(present) > 1 {
+			return &PolicyViolation{
+				Rule:   "mutually_exclusive",
+				Reason: fmt.Sprintf("%v cannot appear together", present),
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchAny reports whether identifier matches any of patterns.
+func matchAny(groups GroupResolver, patterns []string, identifier string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := matchPattern(groups, pattern, identifier)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchPattern reports whether identifier matches pattern, which may be a
+// glob, a "regex:"-prefixed regular expression, or a "group:"-prefixed
+// group name resolved by groups.
+func matchPattern(groups GroupResolver, pattern, identifier string) (bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, "regex:"):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:"))
+		if err != nil {
+			return false, errgo.Notef(err, "invalid regex pattern %q", pattern)
+		}
+		return re.MatchString(identifier), nil
+	case strings.HasPrefix(pattern, "group:"):
+		if groups == nil {
+			return false, errgo.Newf("pattern %q requires a GroupResolver, but none is configured", pattern)
+		}
+		members, err := groups.ResolveGroup(strings.TrimPrefix(pattern, "group:"))
+		if err != nil {
+			return false, errgo.Mask(err, errgo.Any)
+		}
+		for _, member := range members {
+			if member == identifier {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		matched, err := path.Match(pattern, identifier)
+		if err != nil {
+			return false, errgo.Notef(err, "invalid glob pattern %q", pattern)
+		}
+		return matched, nil
+	}
+}
+
+// StaticPolicyEngine enforces a fixed set of PolicyRules, typically loaded
+// once at startup from a config file.
+type StaticPolicyEngine struct {
+	Rules  PolicyRules
+	Groups GroupResolver
+}
+
+// NewStaticPolicyEngine returns a StaticPolicyEngine whose rules are decoded
+// as JSON from r, resolving any "group:" patterns against groups.
+func NewStaticPolicyEngine(r io.Reader, groups GroupResolver) (*StaticPolicyEngine, error) {
+	var rules PolicyRules
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, errgo.Notef(err, "invalid policy rules")
+	}
+	return &StaticPolicyEngine{Rules: rules, Groups: groups}, nil
+}
+
+// CheckPolicy implements PolicyEngine.
+func (e *StaticPolicyEngine) CheckPolicy(policy Policy) error {
+	return e.Rules.Check(policy, e.Groups)
+}
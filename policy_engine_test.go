@@ -0,0 +1,128 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+import (
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+)
+
+type PolicyEngineSuite struct{}
+
+var _ = gc.Suite(&PolicyEngineSuite{})
+
+func participants(recipients ...string) []quorum.ParticipantRef {
+	refs := make([]quorum.ParticipantRef, len(recipients))
+	for i, r := range recipients {
+		refs[i] = quorum.ParticipantRef{KeyID: r + "-key", Recipient: r}
+	}
+	return refs
+}
+
+func (s *PolicyEngineSuite) TestAllowList(c *gc.C) {
+	engine := &quorum.StaticPolicyEngine{Rules: quorum.PolicyRules{
+		Allow: []string{"*@example.com"},
+	}}
+	err := engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("alice@example.com"),
+	})
+	c.Assert(err, gc.IsNil)
+
+	err = engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("mallory@evil.com"),
+	})
+	c.Assert(err, gc.ErrorMatches, `policy rule "allow" violated: .*`)
+}
+
+func (s *PolicyEngineSuite) TestDenyList(c *gc.C) {
+	engine := &quorum.StaticPolicyEngine{Rules: quorum.PolicyRules{
+		Deny: []string{"regex:^intern-.*@example\\.com$"},
+	}}
+	err := engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("intern-bob@example.com"),
+	})
+	c.Assert(err, gc.ErrorMatches, `policy rule "deny" violated: .*`)
+}
+
+func (s *PolicyEngineSuite) TestGroupMembership(c *gc.C) {
+	engine := &quorum.StaticPolicyEngine{
+		Rules: quorum.PolicyRules{
+			RequireOneOf: [][]string{{"group:oncall@"}},
+		},
+		Groups: quorum.StaticGroupResolver{
+			"oncall@": {"alice@example.com", "bob@example.com"},
+		},
+	}
+	err := engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("alice@example.com", "carol@example.com"),
+	})
+	c.Assert(err, gc.IsNil)
+
+	err = engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("carol@example.com"),
+	})
+	c.Assert(err, gc.ErrorMatches, `policy rule "require_one_of" violated: .*`)
+}
+
+func (s *PolicyEngineSuite) TestMutuallyExclusive(c *gc.C) {
+	engine := &quorum.StaticPolicyEngine{Rules: quorum.PolicyRules{
+		MutuallyExclusive: [][]string{{"alice@example.com", "bob@example.com"}},
+	}}
+	err := engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("alice@example.com", "bob@example.com"),
+	})
+	c.Assert(err, gc.ErrorMatches, `policy rule "mutually_exclusive" violated: .*`)
+
+	err = engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("alice@example.com", "carol@example.com"),
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *PolicyEngineSuite) TestMinApprovalsRequired(c *gc.C) {
+	engine := &quorum.StaticPolicyEngine{Rules: quorum.PolicyRules{
+		MinApprovalsRequired: 2,
+	}}
+	err := engine.CheckPolicy(quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("alice@example.com", "bob@example.com"),
+	})
+	c.Assert(err, gc.ErrorMatches, `policy rule "min_approvals_required" violated: .*`)
+}
+
+func (s *PolicyEngineSuite) TestValidateInvokesEngine(c *gc.C) {
+	policy := quorum.Policy{
+		NApprovalsRequired: 1,
+		Participants:       participants("mallory@evil.com"),
+	}
+	engine := &quorum.StaticPolicyEngine{Rules: quorum.PolicyRules{
+		Allow: []string{"*@example.com"},
+	}}
+	err := policy.Validate(engine)
+	c.Assert(err, gc.ErrorMatches, `policy rule "allow" violated: .*`)
+
+	err = policy.Validate(nil)
+	c.Assert(err, gc.IsNil)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package slack implements quorum.Sender by posting ballots to a Slack
+// channel or user through an incoming webhook, addressed by a "slack:" URI
+// such as "slack:#approvals" or "slack:@alice".
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/quorum"
+)
+
+// Sender posts ballots to Slack via an incoming webhook URL.
+type Sender struct {
+	// WebhookURL is the Slack incoming webhook endpoint to post to.
+	WebhookURL string
+	// Client makes the HTTP request. Defaults to http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewSender returns a Sender that posts ballots to the Slack incoming
+// webhook at webhookURL.
+func NewSender(webhookURL string) *Sender {
+	return &Sender{WebhookURL: webhookURL}
+}
+
+// ValidateRecipient implements quorum.Sender by checking that recipient is a
+// well-formed "slack:" URI naming a channel or user.
+func (s *Sender) ValidateRecipient(recipient string) error {
+	_, err := parseTarget(recipient)
+	return errgo.Mask(err, errgo.Any)
+}
+
+// Send implements quorum.Sender by posting the ballot to Slack.
+func (s *Sender) Send(ballot quorum.Ballot) error {
+	target, err := parseTarget(ballot.Recipient)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	payload, err := json.Marshal(map[string]string{
+		"channel": target,
+		"text":    fmt.Sprintf("Ballot %s: %s", ballot.ID, ballot.Message),
+	})
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(s.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errgo.Newf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// parseTarget extracts the channel or user name from a "slack:" recipient
+// URI. A leading "#" on the channel name is parsed by net/url as the start
+// of the URI's fragment rather than its opaque part, so the two are
+// recombined here.
+func parseTarget(recipient string) (string, error) {
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return "", errgo.Notef(err, "invalid recipient %q", recipient)
+	}
+	if u.Scheme != "slack" {
+		return "", errgo.Newf("recipient %q is not a slack: target", recipient)
+	}
+	target := u.Opaque
+	if u.Fragment != "" {
+		target += "#" + u.Fragment
+	}
+	if !strings.HasPrefix(target, "#") && !strings.HasPrefix(target, "@") {
+		return "", errgo.Newf("slack target %q must name a channel (#...) or user (@...)", target)
+	}
+	return target, nil
+}
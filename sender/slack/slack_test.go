@@ -0,0 +1,57 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package slack_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+	"github.com/cmars/quorum/sender/slack"
+)
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SenderSuite struct{}
+
+var _ = gc.Suite(&SenderSuite{})
+
+func (s *SenderSuite) TestValidateRecipient(c *gc.C) {
+	sender := slack.NewSender("https://hooks.slack.example.com/services/x")
+	c.Assert(sender.ValidateRecipient("slack:#approvals"), gc.IsNil)
+	c.Assert(sender.ValidateRecipient("slack:@alice"), gc.IsNil)
+	c.Assert(sender.ValidateRecipient("https://example.com"), gc.ErrorMatches, `.*not a slack: target`)
+	c.Assert(sender.ValidateRecipient("slack:approvals"), gc.ErrorMatches, `.*must name a channel.*`)
+}
+
+func (s *SenderSuite) TestSendPostsToChannel(c *gc.C) {
+	var posted map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(json.NewDecoder(r.Body).Decode(&posted), gc.IsNil)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := slack.NewSender(server.URL)
+	err := sender.Send(quorum.Ballot{ID: "ballot-id", Recipient: "slack:#approvals", Message: "approve?"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(posted["channel"], gc.Equals, "#approvals")
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package smtp implements quorum.Sender by emailing ballots to recipients
+// addressed by a "mailto:" URI.
+package smtp
+
+import (
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/quorum"
+)
+
+// Sender delivers ballots by email through an SMTP relay.
+type Sender struct {
+	// Addr is the SMTP server address, e.g. "smtp.example.com:587".
+	Addr string
+	// From is the envelope sender address used for outgoing mail.
+	From string
+	// Auth authenticates to the SMTP server at Addr, if required.
+	Auth smtp.Auth
+}
+
+// NewSender returns a Sender that relays ballots through the SMTP server at
+// addr, authenticating with auth if non-nil.
+func NewSender(addr, from string, auth smtp.Auth) *Sender {
+	return &Sender{Addr: addr, From: from, Auth: auth}
+}
+
+// ValidateRecipient implements quorum.Sender by checking that recipient is a
+// well-formed "mailto:" URI.
+func (s *Sender) ValidateRecipient(recipient string) error {
+	_, err := parseMailto(recipient)
+	return errgo.Mask(err, errgo.Any)
+}
+
+// Send implements quorum.Sender by emailing the ballot to its recipient.
+func (s *Sender) Send(ballot quorum.Ballot) error {
+	addr, err := parseMailto(ballot.Recipient)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if containsCRLF(ballot.Message) {
+		return errgo.Newf("ballot message for %q contains a CR or LF", ballot.Recipient)
+	}
+	msg := fmt.Sprintf("To: %s\r\nSubject: Quorum ballot %s\r\n\r\n%s\r\n",
+		addr, ballot.ID, ballot.Message)
+	err = smtp.SendMail(s.Addr, s.Auth, s.From, []string{addr}, []byte(msg))
+	return errgo.Mask(err, errgo.Any)
+}
+
+// parseMailto extracts the email address from a "mailto:" recipient URI.
+// The recipient comes straight from the caveat-supplied Policy.Participants,
+// so it's rejected outright (rather than sanitized) if it isn't a single
+// well-formed address, or contains a CR/LF that could be used to smuggle
+// extra SMTP headers into the outgoing message.
+func parseMailto(recipient string) (string, error) {
+	if containsCRLF(recipient) {
+		return "", errgo.Newf("recipient %q contains a CR or LF", recipient)
+	}
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return "", errgo.Notef(err, "invalid recipient %q", recipient)
+	}
+	if u.Scheme != "mailto" {
+		return "", errgo.Newf("recipient %q is not a mailto: address", recipient)
+	}
+	addr, err := mail.ParseAddress(u.Opaque)
+	if err != nil {
+		return "", errgo.Notef(err, "invalid recipient %q", recipient)
+	}
+	return addr.Address, nil
+}
+
+// containsCRLF reports whether s contains a carriage return or line feed,
+// either of which could be used to inject extra header lines into a raw
+// SMTP message.
+func containsCRLF(s string) bool {
+	return strings.ContainsAny(s, "\r\n")
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package smtp_test
+
+import (
+	"testing"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+	"github.com/cmars/quorum/sender/smtp"
+)
+
+func Test(t *testing.T) { gc.TestingT(t) }
+
+type SenderSuite struct{}
+
+var _ = gc.Suite(&SenderSuite{})
+
+func (s *SenderSuite) TestValidateRecipient(c *gc.C) {
+	sender := smtp.NewSender("smtp.example.com:587", "quorum@example.com", nil)
+	c.Assert(sender.ValidateRecipient("mailto:alice@example.com"), gc.IsNil)
+	c.Assert(sender.ValidateRecipient("https://example.com"), gc.ErrorMatches, `.*not a mailto: address`)
+}
+
+func (s *SenderSuite) TestValidateRecipientRejectsHeaderInjection(c *gc.C) {
+	sender := smtp.NewSender("smtp.example.com:587", "quorum@example.com", nil)
+	err := sender.ValidateRecipient("mailto:victim@example.com\r\nBcc: attacker@evil.com")
+	c.Assert(err, gc.ErrorMatches, `.*contains a CR or LF`)
+}
+
+func (s *SenderSuite) TestSendRejectsMessageWithCRLF(c *gc.C) {
+	sender := smtp.NewSender("smtp.example.com:587", "quorum@example.com", nil)
+	err := sender.Send(quorum.Ballot{
+		Recipient: "mailto:alice@example.com",
+		Message:   "hello\r\nBcc: attacker@evil.com",
+	})
+	c.Assert(err, gc.ErrorMatches, `.*contains a CR or LF`)
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webhook implements quorum.Sender by POSTing ballots as JSON to an
+// arbitrary "http:" or "https:" recipient URL.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/quorum"
+)
+
+// Sender delivers ballots by POSTing them as JSON to the recipient URL.
+type Sender struct {
+	// Client makes the HTTP request. Defaults to http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewSender returns a webhook Sender.
+func NewSender() *Sender {
+	return &Sender{}
+}
+
+// ValidateRecipient implements quorum.Sender by checking that recipient is a
+// well-formed http(s) URL.
+func (s *Sender) ValidateRecipient(recipient string) error {
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return errgo.Notef(err, "invalid recipient %q", recipient)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errgo.Newf("recipient %q is not an http(s) URL", recipient)
+	}
+	return nil
+}
+
+// Send implements quorum.Sender by POSTing the ballot as JSON to its
+// recipient URL.
+func (s *Sender) Send(ballot quorum.Ballot) error {
+	if err := s.ValidateRecipient(ballot.Recipient); err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	payload, err := json.Marshal(ballot)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(ballot.Recipient, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errgo.Newf("webhook %s returned %s", ballot.Recipient, resp.Status)
+	}
+	return nil
+}
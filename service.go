@@ -19,17 +19,30 @@
 package quorum
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"gopkg.in/errgo.v1"
 	"gopkg.in/macaroon-bakery.v1/bakery"
 	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
 	"gopkg.in/macaroon-bakery.v1/httpbakery"
+	"gopkg.in/macaroon.v1"
+)
+
+// defaultWaitTimeout is how long a long-poll GET /wait/:election blocks for
+// when the client doesn't supply a ?timeout=, and maxWaitTimeout bounds
+// whatever the client asks for.
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
 )
 
 // Service is the quorum caveat discharging service.
@@ -38,26 +51,65 @@ type Service struct {
 	mux    *http.ServeMux
 	sender Sender
 	store  Storage
+	keys   KeyResolver
+	merger CaveatMerger
+	policy PolicyEngine
+
+	ballotRetention time.Duration
 
 	root, prefix string
 }
 
 // ServiceConfig is used to configure a new Service.
 type ServiceConfig struct {
-	Prefix string
+	Prefix       string
+	Keys         KeyResolver
+	CaveatMerger CaveatMerger
+	// PolicyEngine is consulted to decide whether a caveat-supplied Policy
+	// is permitted to run as an election, before any ballots are created
+	// for it. Defaults to an empty StaticPolicyEngine, which permits
+	// everything.
+	PolicyEngine PolicyEngine
+	// ReapInterval is how often the background reaper checks for elections
+	// whose Deadline has passed. Defaults to defaultReapInterval.
+	ReapInterval time.Duration
+	// Storage persists elections and ballots across requests. Defaults to
+	// NewMemStorage, which does not survive a process restart.
+	Storage Storage
+	// Sender delivers ballots to their recipients. There is no default;
+	// NewService returns an error if it's unset.
+	Sender Sender
+	// BallotRetention is how long a closed election and its ballots (and
+	// their approvers' Signatures) remain queryable for audit before the
+	// reaper purges them. Defaults to defaultBallotRetention.
+	BallotRetention time.Duration
 }
 
+// defaultReapInterval is used when ServiceConfig.ReapInterval is zero.
+const defaultReapInterval = 30 * time.Second
+
+// defaultBallotRetention is used when ServiceConfig.BallotRetention is zero.
+const defaultBallotRetention = 7 * 24 * time.Hour
+
 // Policy defines what constitutes a quorum of approval, and is encoded as the
 // third-party caveat condition to the quorum service.
 type Policy struct {
 	NApprovalsRequired int
-	Participants       []string
+	Participants       []ParticipantRef
 	Message            string
+	// MaxCaveats limits the number of caveats an individual approver may
+	// attach to their ballot.
+	MaxCaveats int
+	// Deadline, if set, is when the election expires. An expired election
+	// is closed out as denied by the reaper, and any waiters are released
+	// with a 410 Gone.
+	Deadline time.Time
 }
 
 // Validate returns an error if the Policy is invalid. For example, if the
-// Policy cannot ever be satisfied.
-func (p *Policy) Validate() error {
+// Policy cannot ever be satisfied, or if engine rejects it. engine may be
+// nil, in which case no policy rules are consulted.
+func (p *Policy) Validate(engine PolicyEngine) error {
 	if len(p.Participants) == 0 {
 		return errgo.Newf("no recipients specified")
 	}
@@ -65,6 +117,14 @@ func (p *Policy) Validate() error {
 		return errgo.Newf("%d recipients will never satisfy %d approver requirement",
 			len(p.Participants), p.NApprovalsRequired)
 	}
+	if !p.Deadline.IsZero() && !p.Deadline.After(time.Now()) {
+		return errgo.Newf("deadline %s has already passed", p.Deadline)
+	}
+	if engine != nil {
+		if err := engine.CheckPolicy(*p); err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+	}
 	return nil
 }
 
@@ -77,6 +137,14 @@ type Election struct {
 
 	NApprovals int
 	NDenials   int
+	// Expired is set by the reaper when the election's Deadline passes
+	// before a quorum is reached.
+	Expired bool
+	// ClosedAt is set by Close once the election has reached a final
+	// result, whether by discharge or by being reaped as denied. A closed
+	// election and its ballots (including their Signatures) stay queryable
+	// for auditing until PurgeClosed removes them.
+	ClosedAt time.Time
 }
 
 // ElectionResult describes the current outcome of the election.
@@ -104,20 +172,79 @@ type Ballot struct {
 	ID        string
 	Election  string
 	Recipient string
+	KeyID     string
 	Message   string
 	Used      bool
+	Signature *Signature
+	// Caveats are the attenuation caveats the approver attached to their
+	// vote, to be baked into the final discharge macaroon.
+	Caveats []checkers.Caveat
+	// DeliveryStatus reports whether the Sender has gotten this ballot to
+	// its recipient yet.
+	DeliveryStatus DeliveryStatus
 }
 
+// DeliveryStatus describes the outcome of attempting to send a Ballot to
+// its recipient.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   = DeliveryStatus("pending")
+	DeliveryDelivered = DeliveryStatus("delivered")
+	DeliveryFailed    = DeliveryStatus("failed")
+)
+
 // ErrNotFound indicates a storage lookup did not match.
 var ErrNotFound = errgo.New("not found")
 
+// errInvalidVote is the cause reported by verifyVote when the POST body of
+// an approve/deny request can't be decoded, distinguishing it from a
+// not-found ballot/election or a bad signature.
+var errInvalidVote = errgo.New("invalid vote request")
+
 // Storage defines the interface for persisting elections across requests.
 type Storage interface {
 	Add(election Election, ballots []Ballot) error
-	Approve(ballot string) error
-	Deny(ballot string) error
+	Approve(ballot string, sig Signature, caveats []checkers.Caveat) error
+	Deny(ballot string, sig Signature) error
 	Election(id string) (Election, error)
+	Ballot(id string) (Ballot, error)
+	// Ballots returns every ballot issued for the named election, for
+	// reporting per-ballot delivery status.
+	Ballots(electionID string) ([]Ballot, error)
+	// SetDeliveryStatus records the outcome of the Sender's latest attempt
+	// to deliver a ballot to its recipient.
+	SetDeliveryStatus(ballot string, status DeliveryStatus) error
+	// Caveats returns the union of the caveats attached by every approver
+	// of the named election.
+	Caveats(electionID string) ([]checkers.Caveat, error)
+	// Watch returns a channel that receives the election's state whenever
+	// it changes, so callers can block on real state changes instead of
+	// polling. The channel is closed once ctx is done; depending on the
+	// backend it may be closed earlier (memStorage closes it when the
+	// election is closed, sqlStorage when the election no longer exists).
+	// Callers must cancel ctx when they're done watching, or the watch (a
+	// goroutine, a DB poll, or a live watch stream, depending on the
+	// backend) leaks for the life of the process.
+	Watch(ctx context.Context, electionID string) <-chan Election
+	// ReapExpired closes out, as denied, every election whose Deadline has
+	// passed and is still pending, unblocking any waiters with 410 Gone.
+	ReapExpired(now time.Time) error
+	// CloseDenied calls Close on every election that has reached a final
+	// denied result (whether by vote or by ReapExpired); nobody will ever
+	// discharge one, so nothing else ever closes it out.
+	CloseDenied() error
+	// Close marks the election as settled by recording its ClosedAt time.
+	// memStorage also releases any waiters watching it by closing their
+	// Watch channels; the other backends leave their watches open until
+	// the watcher's ctx is done. The election and its ballots
+	// (including their Signatures) are retained for audit until a later
+	// PurgeClosed call removes them; Close itself never deletes anything.
 	Close(id string) error
+	// PurgeClosed permanently deletes every election, and its ballots, whose
+	// Close happened before the given time, so audit records don't grow
+	// without bound once their retention window has elapsed.
+	PurgeClosed(before time.Time) error
 }
 
 // Sender defines the interface for validating and contacting participants with
@@ -129,23 +256,95 @@ type Sender interface {
 
 // NewService returns a new Service instance.
 func NewService(config ServiceConfig) (*Service, error) {
+	if config.Keys == nil {
+		return nil, errgo.Newf("ServiceConfig.Keys is required")
+	}
+	if config.Sender == nil {
+		return nil, errgo.Newf("ServiceConfig.Sender is required")
+	}
+
 	bakeryService, err := bakery.NewService(bakery.NewServiceParams{})
 	if err != nil {
 		return nil, errgo.Mask(err, errgo.Any)
 	}
 
-	s := &Service{bakery: bakeryService, prefix: config.Prefix}
+	merger := config.CaveatMerger
+	if merger == nil {
+		merger = UnionCaveatMerger{}
+	}
+	policyEngine := config.PolicyEngine
+	if policyEngine == nil {
+		policyEngine = &StaticPolicyEngine{}
+	}
+	store := config.Storage
+	if store == nil {
+		store = NewMemStorage()
+	}
+	ballotRetention := config.BallotRetention
+	if ballotRetention <= 0 {
+		ballotRetention = defaultBallotRetention
+	}
+	s := &Service{
+		bakery:          bakeryService,
+		prefix:          config.Prefix,
+		keys:            config.Keys,
+		merger:          merger,
+		policy:          policyEngine,
+		store:           store,
+		sender:          config.Sender,
+		ballotRetention: ballotRetention,
+	}
 
 	s.mux = http.NewServeMux()
 	httpbakery.AddDischargeHandler(s.mux, config.Prefix+"/discharger", s.bakery, s.checker)
 	r := httprouter.New()
 	r.GET(config.Prefix+"/wait/:election", s.wait)
-	r.GET(config.Prefix+"/approve/:ballot", s.approve)
-	r.GET(config.Prefix+"/deny/:ballot", s.deny)
+	r.GET(config.Prefix+"/elections/:election/status", s.status)
+	r.POST(config.Prefix+"/approve/:ballot", s.approve)
+	r.POST(config.Prefix+"/deny/:ballot", s.deny)
 	s.mux.Handle("/", r)
+
+	reapInterval := config.ReapInterval
+	if reapInterval <= 0 {
+		reapInterval = defaultReapInterval
+	}
+	go s.reap(reapInterval)
+
 	return s, nil
 }
 
+// reap periodically closes out, as denied, any election whose Deadline has
+// passed, so waiters blocked in wait aren't left pending forever; closes
+// out every election that has reached a final denied result, so their
+// watchers don't accumulate forever; and purges elections (and their
+// ballots) that were closed longer ago than ballotRetention, so the audit
+// trail they're retained for doesn't grow without bound.
+func (s *Service) reap(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.store.ReapExpired(time.Now()); err != nil {
+			log.Printf("reap expired elections: %s", errgo.Details(err))
+		}
+		if err := s.store.CloseDenied(); err != nil {
+			log.Printf("close denied elections: %s", errgo.Details(err))
+		}
+		if err := s.store.PurgeClosed(time.Now().Add(-s.ballotRetention)); err != nil {
+			log.Printf("purge closed elections: %s", errgo.Details(err))
+		}
+	}
+}
+
+// voteRequest is the POST body accepted by the approve/deny endpoints,
+// carrying a detached signature over the ballot decision. Caveats is only
+// meaningful on approve: it names the attenuation caveats the approver
+// wants baked into the final discharge macaroon.
+type voteRequest struct {
+	Timestamp int64
+	Signature []byte
+	Caveats   []checkers.Caveat
+}
+
 // ServeHTTP implements http.Handler.
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
@@ -185,7 +384,7 @@ func (s *Service) newElection(cavID, cav string) (string, error) {
 	if err != nil {
 		return fail, errgo.Notef(err, "invalid caveat %q", cav)
 	}
-	err = policy.Validate()
+	err = policy.Validate(s.policy)
 	if err != nil {
 		return fail, errgo.Mask(err, errgo.Any)
 	}
@@ -197,8 +396,12 @@ func (s *Service) newElection(cavID, cav string) (string, error) {
 	election := Election{ID: electionID, CaveatID: cavID, Policy: policy}
 
 	var ballots []Ballot
-	for _, recipient := range policy.Participants {
-		err := s.sender.ValidateRecipient(recipient)
+	for _, participant := range policy.Participants {
+		_, err := s.keys.ResolveKey(participant.KeyID)
+		if err != nil {
+			return fail, errgo.Notef(err, "cannot resolve key %q", participant.KeyID)
+		}
+		err = s.sender.ValidateRecipient(participant.Recipient)
 		if err != nil {
 			return fail, errgo.Mask(err)
 		}
@@ -207,10 +410,12 @@ func (s *Service) newElection(cavID, cav string) (string, error) {
 			return fail, errgo.Mask(err)
 		}
 		ballots = append(ballots, Ballot{
-			ID:        ballotID,
-			Election:  electionID,
-			Recipient: recipient,
-			Message:   policy.Message,
+			ID:             ballotID,
+			Election:       electionID,
+			Recipient:      participant.Recipient,
+			KeyID:          participant.KeyID,
+			Message:        policy.Message,
+			DeliveryStatus: DeliveryPending,
 		})
 	}
 
@@ -219,15 +424,78 @@ func (s *Service) newElection(cavID, cav string) (string, error) {
 		return fail, errgo.Mask(err, errgo.Any)
 	}
 
+	err = s.sendBallots(policy, ballots)
+	if err != nil {
+		if closeErr := s.store.Close(electionID); closeErr != nil {
+			log.Printf("closing election %q after failed fan-out: %s", electionID, errgo.Details(closeErr))
+		}
+		return fail, errgo.Mask(err, errgo.Any)
+	}
+	return electionID, nil
+}
+
+// sendMaxAttempts and sendInitialBackoff bound the per-recipient retry
+// performed by sendBallots before giving up on a ballot.
+const (
+	sendMaxAttempts    = 3
+	sendInitialBackoff = 500 * time.Millisecond
+)
+
+// sendBallots delivers ballots to their recipients concurrently, retrying
+// each with exponential backoff. A single unreachable recipient shouldn't
+// doom the whole election, so this only returns an error once so many
+// ballots have failed to send that the policy's quorum can no longer be
+// reached: that happens once fewer than NApprovalsRequired recipients are
+// left with any chance of receiving their ballot.
+func (s *Service) sendBallots(policy Policy, ballots []Ballot) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var nFailed int
+
 	for _, ballot := range ballots {
+		wg.Add(1)
+		go func(ballot Ballot) {
+			defer wg.Done()
+			status := s.sendWithRetry(ballot)
+			err := s.store.SetDeliveryStatus(ballot.ID, status)
+			if err != nil {
+				log.Printf("recording delivery status for ballot %q: %s", ballot.ID, errgo.Details(err))
+			}
+			if status == DeliveryFailed {
+				mu.Lock()
+				nFailed++
+				mu.Unlock()
+			}
+		}(ballot)
+	}
+	wg.Wait()
+
+	maxFailures := len(policy.Participants) - policy.NApprovalsRequired
+	if nFailed > maxFailures {
+		return errgo.Newf("%d of %d ballots failed to send; quorum of %d is no longer reachable",
+			nFailed, len(ballots), policy.NApprovalsRequired)
+	}
+	return nil
+}
+
+// sendWithRetry attempts to deliver ballot, retrying with exponential
+// backoff, and reports the resulting delivery status.
+func (s *Service) sendWithRetry(ballot Ballot) DeliveryStatus {
+	backoff := sendInitialBackoff
+	var err error
+	for attempt := 0; attempt < sendMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
 		err = s.sender.Send(ballot)
-		if err != nil {
-			log.Println("failed to send ballot to %q: %v", ballot.Recipient, err)
-			// TODO: allow some failures depending on the quorum required?
-			return fail, errgo.Mask(err, errgo.Any)
+		if err == nil {
+			return DeliveryDelivered
 		}
 	}
-	return electionID, nil
+	log.Printf("failed to send ballot to %q after %d attempts: %s",
+		ballot.Recipient, sendMaxAttempts, errgo.Details(errgo.Mask(err, errgo.Any)))
+	return DeliveryFailed
 }
 
 func httpErrorf(w http.ResponseWriter, statusCode int, err error) {
@@ -235,35 +503,108 @@ func httpErrorf(w http.ResponseWriter, statusCode int, err error) {
 	log.Printf("HTTP %d: %s", statusCode, errgo.Details(err))
 }
 
+// wait reports the state of an election. A plain GET long-polls, blocking up
+// to a client-supplied (server-bounded) ?timeout= until the election leaves
+// the pending state; a GET with "Accept: text/event-stream" instead streams
+// pending/approved/denied events as they happen. Either way, this replaces
+// the busy client polling loop that an immediate 202 would otherwise force.
 func (s *Service) wait(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	id := p.ByName("election")
 	if id == "" {
 		httpErrorf(w, http.StatusBadRequest, errgo.New("missing election param"))
 		return
 	}
-	election, err := s.store.Election(id)
+
+	if r.Header.Get("Accept") == "text/event-stream" {
+		s.waitStream(w, r, id)
+		return
+	}
+
+	election, err := s.awaitElection(r, id)
 	if err != nil {
 		httpErrorf(w, http.StatusBadRequest, errgo.Mask(err, errgo.Any))
 		return
 	}
+	s.respondElection(w, election)
+}
+
+// awaitElection returns the current state of the election, blocking until it
+// leaves the pending state, the request is cancelled, or the wait timeout
+// elapses. The watch is registered before the election is read, so a
+// decision landing in between can't be missed; every watch update is
+// re-checked for a terminal result rather than trusting the first one,
+// since a quorum of more than one approval fires a watch notification on
+// every vote, not just the one that settles it. The timeout branch re-reads
+// storage rather than trusting the last pending snapshot, in case the
+// decision still slipped past the watch (e.g. a poll-based Storage hasn't
+// caught up yet). The watch's context is cancelled before returning, so the
+// backend can free whatever it used to implement it.
+func (s *Service) awaitElection(r *http.Request, id string) (Election, error) {
+	var fail Election
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	ch := s.store.Watch(ctx, id)
+	election, err := s.store.Election(id)
+	if err != nil {
+		return fail, errgo.Mask(err, errgo.Any)
+	}
+	if election.Result() != ElectionPending {
+		return election, nil
+	}
+
+	timeout := time.NewTimer(waitTimeout(r))
+	defer timeout.Stop()
+	for {
+		select {
+		case updated, ok := <-ch:
+			if !ok {
+				return s.store.Election(id)
+			}
+			if updated.Result() != ElectionPending {
+				return updated, nil
+			}
+		case <-timeout.C:
+			return s.store.Election(id)
+		case <-r.Context().Done():
+			return fail, errgo.Mask(r.Context().Err())
+		}
+	}
+}
+
+// waitTimeout returns the bounded long-poll timeout requested by ?timeout=,
+// falling back to defaultWaitTimeout if it's absent or invalid.
+func waitTimeout(r *http.Request) time.Duration {
+	raw := r.URL.Query().Get("timeout")
+	if raw == "" {
+		return defaultWaitTimeout
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > maxWaitTimeout {
+		return defaultWaitTimeout
+	}
+	return d
+}
+
+// respondElection writes the HTTP response for the (now-settled, or still
+// pending after a timeout) state of an election.
+func (s *Service) respondElection(w http.ResponseWriter, election Election) {
 	switch election.Result() {
 	case ElectionPending:
 		w.WriteHeader(http.StatusAccepted)
 	case ElectionDenied:
-		w.WriteHeader(http.StatusForbidden)
-	case ElectionApproved:
-		dm, err := s.bakery.Discharge(nil, election.CaveatID)
-		if err != nil {
-			httpErrorf(w, http.StatusInternalServerError, errgo.Mask(err, errgo.Any))
+		if election.Expired {
+			w.WriteHeader(http.StatusGone)
 			return
 		}
-
-		err = s.store.Close(election.ID)
+		w.WriteHeader(http.StatusForbidden)
+	case ElectionApproved:
+		dm, err := s.dischargeElection(election)
 		if err != nil {
 			httpErrorf(w, http.StatusInternalServerError, errgo.Mask(err, errgo.Any))
 			return
 		}
-
 		w.WriteHeader(http.StatusCreated)
 		enc := json.NewEncoder(w)
 		err = enc.Encode(dm)
@@ -275,12 +616,164 @@ func (s *Service) wait(w http.ResponseWriter, r *http.Request, p httprouter.Para
 	}
 }
 
+// dischargeElection merges the approvers' caveats, obtains the discharge
+// macaroon for an approved election, and closes it out in storage.
+func (s *Service) dischargeElection(election Election) (*macaroon.Macaroon, error) {
+	caveats, err := s.store.Caveats(election.ID)
+	if err != nil {
+		return nil, errgo.Mask(err, errgo.Any)
+	}
+	merged, err := s.merger.Merge(caveats)
+	if err != nil {
+		return nil, errgo.Notef(err, "approvers' caveats are contradictory")
+	}
+	dm, err := s.bakery.Discharge(caveatChecker{caveats: merged}, election.CaveatID)
+	if err != nil {
+		return nil, errgo.Mask(err, errgo.Any)
+	}
+	err = s.store.Close(election.ID)
+	if err != nil {
+		return nil, errgo.Mask(err, errgo.Any)
+	}
+	return dm, nil
+}
+
+// waitStream serves GET /wait/:election as a server-sent-events stream,
+// writing a new event every time the election's state changes, until it
+// reaches a terminal (approved/denied) state. The watch is registered
+// before the election is read, so a decision landing in between isn't
+// missed, and its context is cancelled before returning so the backend can
+// free whatever it used to implement it.
+func (s *Service) waitStream(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpErrorf(w, http.StatusInternalServerError, errgo.New("streaming not supported"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	ch := s.store.Watch(ctx, id)
+	election, err := s.store.Election(id)
+	if err != nil {
+		httpErrorf(w, http.StatusBadRequest, errgo.Mask(err, errgo.Any))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		if s.writeEvent(w, election) {
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case updated, ok := <-ch:
+			if !ok {
+				if election, err = s.store.Election(id); err != nil {
+					return
+				}
+				continue
+			}
+			election = updated
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a single SSE event for the election's current state,
+// baking the discharge macaroon into the "approved" event's data. It
+// returns true once a terminal event has been written.
+func (s *Service) writeEvent(w http.ResponseWriter, election Election) bool {
+	switch election.Result() {
+	case ElectionPending:
+		fmt.Fprint(w, "event: pending\ndata: {}\n\n")
+		return false
+	case ElectionApproved:
+		dm, err := s.dischargeElection(election)
+		if err != nil {
+			fmt.Fprintf(w, "event: denied\ndata: {\"error\": %q}\n\n", err.Error())
+			return true
+		}
+		data, err := json.Marshal(dm)
+		if err != nil {
+			fmt.Fprintf(w, "event: denied\ndata: {\"error\": %q}\n\n", err.Error())
+			return true
+		}
+		fmt.Fprintf(w, "event: approved\ndata: %s\n\n", data)
+		return true
+	default:
+		if election.Expired {
+			fmt.Fprint(w, "event: expired\ndata: {}\n\n")
+		} else {
+			fmt.Fprint(w, "event: denied\ndata: {}\n\n")
+		}
+		return true
+	}
+}
+
+// ballotStatus is the per-ballot detail reported by GET
+// /elections/:id/status.
+type ballotStatus struct {
+	Ballot         string         `json:"ballot"`
+	Recipient      string         `json:"recipient"`
+	DeliveryStatus DeliveryStatus `json:"delivery_status"`
+	Used           bool           `json:"used"`
+}
+
+// status reports the delivery state of every ballot in an election, so
+// operators can diagnose why it's stuck waiting on a quorum.
+func (s *Service) status(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id := p.ByName("election")
+	if id == "" {
+		httpErrorf(w, http.StatusBadRequest, errgo.New("missing election param"))
+		return
+	}
+	ballots, err := s.store.Ballots(id)
+	if err != nil {
+		httpErrorf(w, http.StatusBadRequest, errgo.Mask(err, errgo.Any))
+		return
+	}
+	statuses := make([]ballotStatus, len(ballots))
+	for i, ballot := range ballots {
+		statuses[i] = ballotStatus{
+			Ballot:         ballot.ID,
+			Recipient:      ballot.Recipient,
+			DeliveryStatus: ballot.DeliveryStatus,
+			Used:           ballot.Used,
+		}
+	}
+	enc := json.NewEncoder(w)
+	err = enc.Encode(statuses)
+	if err != nil {
+		log.Printf("failed to encode election status: %s", errgo.Details(err))
+	}
+}
+
 func (s *Service) approve(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	ballotID := p.ByName("ballot")
 	if ballotID == "" {
 		httpErrorf(w, http.StatusBadRequest, errgo.New("missing ballot ID"))
+		return
 	}
-	err := s.store.Approve(ballotID)
+	ballot, election, req, err := s.verifyVote(r, ballotID, "approve")
+	if err != nil {
+		httpErrorf(w, voteErrorStatus(err), errgo.Mask(err, errgo.Any))
+		return
+	}
+	if election.MaxCaveats > 0 && len(req.Caveats) > election.MaxCaveats {
+		httpErrorf(w, http.StatusBadRequest, errgo.Newf(
+			"%d caveats exceeds the %d allowed by policy", len(req.Caveats), election.MaxCaveats))
+		return
+	}
+
+	sig := Signature{KeyID: ballot.KeyID, Timestamp: req.Timestamp, Sig: req.Signature}
+	err = s.store.Approve(ballotID, sig, req.Caveats)
 	if err != nil {
 		httpErrorf(w, http.StatusInternalServerError, errgo.Notef(err, "storage failed on 'approve'"))
 		return
@@ -290,11 +783,19 @@ func (s *Service) approve(w http.ResponseWriter, r *http.Request, p httprouter.P
 }
 
 func (s *Service) deny(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	ballot := p.ByName("ballot")
-	if ballot == "" {
+	ballotID := p.ByName("ballot")
+	if ballotID == "" {
 		httpErrorf(w, http.StatusBadRequest, errgo.New("missing ballot ID"))
+		return
+	}
+	ballot, _, req, err := s.verifyVote(r, ballotID, "deny")
+	if err != nil {
+		httpErrorf(w, voteErrorStatus(err), errgo.Mask(err, errgo.Any))
+		return
 	}
-	err := s.store.Deny(ballot)
+
+	sig := Signature{KeyID: ballot.KeyID, Timestamp: req.Timestamp, Sig: req.Signature}
+	err = s.store.Deny(ballotID, sig)
 	if err != nil {
 		httpErrorf(w, http.StatusInternalServerError, errgo.Notef(err, "storage failed on 'deny'"))
 		return
@@ -302,3 +803,51 @@ func (s *Service) deny(w http.ResponseWriter, r *http.Request, p httprouter.Para
 	w.WriteHeader(http.StatusNoContent)
 	w.Write([]byte("denied"))
 }
+
+// voteErrorStatus maps the cause of a verifyVote error to the HTTP status
+// code approve/deny should respond with: 400 for a malformed request body,
+// 404 for an unknown ballot or election, and 401 for anything else (an
+// unresolvable key or a bad signature).
+func voteErrorStatus(err error) int {
+	switch errgo.Cause(err) {
+	case errInvalidVote:
+		return http.StatusBadRequest
+	case ErrNotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// verifyVote decodes the POST body of an approve/deny request and checks
+// that it carries a valid detached signature over the ballot decision.
+func (s *Service) verifyVote(r *http.Request, ballotID, decision string) (Ballot, Election, voteRequest, error) {
+	var fail Ballot
+	var failElection Election
+
+	var req voteRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return fail, failElection, req, errgo.WithCausef(err, errInvalidVote, "invalid vote request")
+	}
+
+	ballot, err := s.store.Ballot(ballotID)
+	if err != nil {
+		return fail, failElection, req, errgo.Mask(err, errgo.Any)
+	}
+	election, err := s.store.Election(ballot.Election)
+	if err != nil {
+		return fail, failElection, req, errgo.Mask(err, errgo.Any)
+	}
+	key, err := s.keys.ResolveKey(ballot.KeyID)
+	if err != nil {
+		return fail, failElection, req, errgo.Notef(err, "cannot resolve key %q", ballot.KeyID)
+	}
+
+	sig := Signature{KeyID: ballot.KeyID, Timestamp: req.Timestamp, Sig: req.Signature}
+	err = sig.Verify(key, ballotID, decision, election.CaveatID)
+	if err != nil {
+		return fail, failElection, req, errgo.Mask(err, errgo.Any)
+	}
+	return ballot, election, req, nil
+}
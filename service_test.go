@@ -0,0 +1,273 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+// This file lives in package quorum, rather than alongside the other tests
+// in quorum_test, because exercising wait/awaitElection and the Watch
+// leak fix needs a Service built directly around a Storage, without the
+// HTTP plumbing NewService sets up, and, for the unwatch test, a peek at
+// memStorage's internal watcher bookkeeping.
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/errgo.v1"
+)
+
+func testElection(id string, nRequired int) (Election, []Ballot) {
+	election := Election{
+		Policy: Policy{
+			NApprovalsRequired: nRequired,
+			Participants:       []ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+		},
+		ID:       id,
+		CaveatID: id + "-caveatid",
+	}
+	ballots := []Ballot{{ID: id + "-ballot", Election: id, Recipient: "alice@e1", KeyID: "alice-key"}}
+	return election, ballots
+}
+
+func TestWaitForbiddenForAlreadyDeniedElection(t *testing.T) {
+	store := NewMemStorage()
+	election, ballots := testElection("election-id", 1)
+	if err := store.Add(election, ballots); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Deny("election-id-ballot", Signature{}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{store: store}
+	req := httptest.NewRequest(http.MethodGet, "/wait/election-id", nil)
+	w := httptest.NewRecorder()
+	s.wait(w, req, httprouter.Params{{Key: "election", Value: "election-id"}})
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAwaitElectionReturnsOnApprove(t *testing.T) {
+	store := NewMemStorage()
+	election, ballots := testElection("election-id", 1)
+	if err := store.Add(election, ballots); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{store: store}
+	req := httptest.NewRequest(http.MethodGet, "/wait/election-id", nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if err := store.Approve("election-id-ballot", Signature{}, nil); err != nil {
+			panic(err)
+		}
+	}()
+
+	got, err := s.awaitElection(req, "election-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Result() != ElectionApproved {
+		t.Fatalf("expected %s, got %s", ElectionApproved, got.Result())
+	}
+}
+
+// TestAwaitElectionWaitsForQuorumNotFirstVote checks that awaitElection
+// doesn't return as soon as any watch notification arrives: a quorum of
+// more than one approval fires a notification on every vote, not just the
+// one that settles the election, so awaitElection must keep blocking past
+// a non-terminal update.
+func TestAwaitElectionWaitsForQuorumNotFirstVote(t *testing.T) {
+	store := NewMemStorage()
+	election := Election{
+		Policy: Policy{
+			NApprovalsRequired: 2,
+			Participants: []ParticipantRef{
+				{KeyID: "alice-key", Recipient: "alice@e1"},
+				{KeyID: "bob-key", Recipient: "bob@e1"},
+			},
+		},
+		ID:       "election-id",
+		CaveatID: "election-id-caveatid",
+	}
+	ballots := []Ballot{
+		{ID: "alice-ballot", Election: "election-id", Recipient: "alice@e1", KeyID: "alice-key"},
+		{ID: "bob-ballot", Election: "election-id", Recipient: "bob@e1", KeyID: "bob-key"},
+	}
+	if err := store.Add(election, ballots); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{store: store}
+	req := httptest.NewRequest(http.MethodGet, "/wait/election-id?timeout=500ms", nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if err := store.Approve("alice-ballot", Signature{}, nil); err != nil {
+			panic(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err := store.Approve("bob-ballot", Signature{}, nil); err != nil {
+			panic(err)
+		}
+	}()
+
+	start := time.Now()
+	got, err := s.awaitElection(req, "election-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("awaitElection returned after %s, before quorum could have been reached", elapsed)
+	}
+	if got.Result() != ElectionApproved {
+		t.Fatalf("expected %s, got %s", ElectionApproved, got.Result())
+	}
+}
+
+// TestAwaitElectionUnwatchesWhenDone checks that awaitElection's watch is
+// torn down once it returns, rather than leaking for the life of the
+// process the way it did before Watch took a cancellable context.
+func TestAwaitElectionUnwatchesWhenDone(t *testing.T) {
+	store := NewMemStorage()
+	election, ballots := testElection("election-id", 1)
+	if err := store.Add(election, ballots); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{store: store}
+	req := httptest.NewRequest(http.MethodGet, "/wait/election-id?timeout=10ms", nil)
+	if _, err := s.awaitElection(req, "election-id"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		n := len(store.watchers["election-id"])
+		store.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watcher still registered after awaitElection returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// alwaysFailSender validates any recipient but never manages to deliver,
+// so sendBallots always gives up and returns an error.
+type alwaysFailSender struct{}
+
+func (alwaysFailSender) ValidateRecipient(recipient string) error { return nil }
+func (alwaysFailSender) Send(ballot Ballot) error                 { return errgo.Newf("delivery refused") }
+
+// TestNewElectionClosesElectionOnFailedFanOut checks that an election whose
+// ballots can't be delivered doesn't linger in storage forever: nobody can
+// ever approve/deny an election ID that was never returned to the caller.
+func TestNewElectionClosesElectionOnFailedFanOut(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemStorage()
+	s := &Service{
+		store:  store,
+		sender: alwaysFailSender{},
+		keys:   StaticKeyResolver{"alice-key": pub},
+		policy: &StaticPolicyEngine{},
+	}
+
+	cav, err := json.Marshal(Policy{
+		NApprovalsRequired: 1,
+		Participants:       []ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.newElection("caveat-id", string(cav))
+	if err == nil {
+		t.Fatal("expected newElection to return an error")
+	}
+
+	store.mu.Lock()
+	n := len(store.elections)
+	store.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no elections left in storage, found %d", n)
+	}
+}
+
+// TestApproveStatusCodes checks that approve distinguishes a malformed
+// request body (400), an unknown ballot (404), and a bad signature (401),
+// rather than collapsing every verifyVote failure to 401.
+func TestApproveStatusCodes(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemStorage()
+	election, ballots := testElection("election-id", 1)
+	if err := store.Add(election, ballots); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{
+		store: store,
+		keys:  StaticKeyResolver{"alice-key": pub},
+	}
+	approve := func(body string, ballotID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/approve/"+ballotID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.approve(w, req, httprouter.Params{{Key: "ballot", Value: ballotID}})
+		return w.Code
+	}
+
+	if code := approve("not json", "election-id-ballot"); code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a malformed body, got %d", http.StatusBadRequest, code)
+	}
+	if code := approve("{}", "no-such-ballot"); code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown ballot, got %d", http.StatusNotFound, code)
+	}
+	if code := approve(`{"Timestamp":1,"Signature":"AA=="}`, "election-id-ballot"); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d for a bad signature, got %d", http.StatusUnauthorized, code)
+	}
+
+	sig := Signature{
+		Timestamp: 1,
+		Sig:       ed25519.Sign(priv, signedMessage("election-id-ballot", "approve", 1, "election-id-caveatid")),
+	}
+	body, err := json.Marshal(voteRequest{Timestamp: sig.Timestamp, Signature: sig.Sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := approve(string(body), "election-id-ballot"); code != http.StatusNoContent {
+		t.Fatalf("expected %d for a valid vote, got %d", http.StatusNoContent, code)
+	}
+}
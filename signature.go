@@ -0,0 +1,80 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"crypto/ed25519"
+	"strconv"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+)
+
+// ParticipantRef identifies a quorum participant by the key that will be
+// used to authenticate their vote, rather than by an opaque name.
+type ParticipantRef struct {
+	// KeyID identifies the participant's public key, resolved through a
+	// KeyResolver (e.g. a JWK thumbprint or key fingerprint).
+	KeyID string
+	// Recipient is the address a ballot is delivered to (mailto:, slack:,
+	// https:, etc).
+	Recipient string
+}
+
+// KeyResolver resolves a participant's public key from its key identifier.
+type KeyResolver interface {
+	ResolveKey(keyID string) (ed25519.PublicKey, error)
+}
+
+// StaticKeyResolver is a KeyResolver backed by a fixed set of known keys,
+// useful for tests and small deployments.
+type StaticKeyResolver map[string]ed25519.PublicKey
+
+// ResolveKey implements KeyResolver.
+func (r StaticKeyResolver) ResolveKey(keyID string) (ed25519.PublicKey, error) {
+	key, ok := r[keyID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+// Signature is a detached signature over a ballot decision, proving that the
+// holder of the named key cast the vote.
+type Signature struct {
+	KeyID     string
+	Timestamp int64
+	Sig       []byte
+}
+
+// signedMessage returns the canonical byte string signed by a participant
+// casting a ballot decision.
+func signedMessage(ballotID, decision string, timestamp int64, caveatID string) []byte {
+	return []byte(strings.Join([]string{
+		ballotID, decision, strconv.FormatInt(timestamp, 10), caveatID,
+	}, "\x00"))
+}
+
+// Verify checks that the signature is valid for the given key over the
+// named ballot decision.
+func (s Signature) Verify(key ed25519.PublicKey, ballotID, decision, caveatID string) error {
+	msg := signedMessage(ballotID, decision, s.Timestamp, caveatID)
+	if !ed25519.Verify(key, msg, s.Sig) {
+		return errgo.Newf("invalid signature for ballot %q", ballotID)
+	}
+	return nil
+}
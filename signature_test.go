@@ -0,0 +1,55 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+import (
+	"crypto/ed25519"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+)
+
+type SignatureSuite struct{}
+
+var _ = gc.Suite(&SignatureSuite{})
+
+func (s *SignatureSuite) TestVerify(c *gc.C) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	c.Assert(err, gc.IsNil)
+
+	resolver := quorum.StaticKeyResolver{"alice-key": pub}
+	key, err := resolver.ResolveKey("alice-key")
+	c.Assert(err, gc.IsNil)
+
+	sig := quorum.Signature{
+		KeyID:     "alice-key",
+		Timestamp: 1234,
+		Sig:       ed25519.Sign(priv, []byte("ballot-id\x00approve\x001234\x00caveat-id")),
+	}
+	err = sig.Verify(key, "ballot-id", "approve", "caveat-id")
+	c.Assert(err, gc.IsNil)
+
+	err = sig.Verify(key, "ballot-id", "deny", "caveat-id")
+	c.Assert(err, gc.ErrorMatches, `invalid signature for ballot "ballot-id"`)
+}
+
+func (s *SignatureSuite) TestResolveKeyNotFound(c *gc.C) {
+	resolver := quorum.StaticKeyResolver{}
+	_, err := resolver.ResolveKey("mallory-key")
+	c.Assert(err, gc.Equals, quorum.ErrNotFound)
+}
@@ -0,0 +1,410 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/macaroon-bakery.v1/bakery/checkers"
+)
+
+// sqlSchema creates the elections and ballots tables used by sqlStorage. It
+// is idempotent, so it can be run against the database on every startup.
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS elections (
+	id TEXT PRIMARY KEY,
+	caveat_id TEXT NOT NULL,
+	n_approvals_required INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	max_caveats INTEGER NOT NULL DEFAULT 0,
+	n_approvals INTEGER NOT NULL DEFAULT 0,
+	n_denials INTEGER NOT NULL DEFAULT 0,
+	participants TEXT NOT NULL,
+	deadline TIMESTAMPTZ,
+	expired BOOLEAN NOT NULL DEFAULT FALSE,
+	closed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS ballots (
+	id TEXT PRIMARY KEY,
+	election_id TEXT NOT NULL REFERENCES elections(id),
+	recipient TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	message TEXT NOT NULL,
+	used BOOLEAN NOT NULL DEFAULT FALSE,
+	signature TEXT,
+	caveats TEXT,
+	delivery_status TEXT NOT NULL DEFAULT 'pending'
+);
+`
+
+// watchPollInterval is how often watchers poll for a changed election.
+// database/sql has no generic change-notification API; a PostgreSQL-backed
+// sqlStorage can be paired with LISTEN/NOTIFY triggers for push-based
+// notification, but that isn't implemented here.
+const watchPollInterval = time.Second
+
+// sqlStorage is a Storage implementation backed by a database/sql driver
+// (PostgreSQL in production), so that elections survive restarts and can be
+// shared across Service replicas behind a load balancer.
+type sqlStorage struct {
+	db *sql.DB
+}
+
+// NewSQLStorage returns a Storage implementation backed by db, creating the
+// elections/ballots schema if it does not already exist.
+func NewSQLStorage(db *sql.DB) (Storage, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, errgo.Notef(err, "creating schema")
+	}
+	return &sqlStorage{db: db}, nil
+}
+
+// Add implements the Storage interface.
+func (s *sqlStorage) Add(election Election, ballots []Ballot) error {
+	participants, err := json.Marshal(election.Participants)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer tx.Rollback()
+
+	var deadline *time.Time
+	if !election.Deadline.IsZero() {
+		deadline = &election.Deadline
+	}
+	_, err = tx.Exec(`INSERT INTO elections
+		(id, caveat_id, n_approvals_required, message, max_caveats, participants, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		election.ID, election.CaveatID, election.NApprovalsRequired, election.Message,
+		election.MaxCaveats, participants, deadline)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, ballot := range ballots {
+		_, err = tx.Exec(`INSERT INTO ballots (id, election_id, recipient, key_id, message)
+			VALUES ($1, $2, $3, $4, $5)`,
+			ballot.ID, ballot.Election, ballot.Recipient, ballot.KeyID, ballot.Message)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	return errgo.Mask(tx.Commit())
+}
+
+// Approve implements the Storage interface.
+func (s *sqlStorage) Approve(id string, sig Signature, caveats []checkers.Caveat) error {
+	return s.vote(id, sig, caveats, "n_approvals")
+}
+
+// Deny implements the Storage interface.
+func (s *sqlStorage) Deny(id string, sig Signature) error {
+	return s.vote(id, sig, nil, "n_denials")
+}
+
+// vote records a ballot decision transactionally: the UPDATE's "used = FALSE"
+// clause acts as a compare-and-swap, so concurrent votes from different
+// Service replicas can't double-count or race the approval threshold.
+func (s *sqlStorage) vote(id string, sig Signature, caveats []checkers.Caveat, tallyColumn string) error {
+	sigJSON, err := json.Marshal(sig)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	caveatsJSON, err := json.Marshal(caveats)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE ballots SET used = TRUE, signature = $1, caveats = $2
+		WHERE id = $3 AND used = FALSE`, sigJSON, caveatsJSON, id)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	var electionID string
+	err = tx.QueryRow(`SELECT election_id FROM ballots WHERE id = $1`, id).Scan(&electionID)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	_, err = tx.Exec(`UPDATE elections SET `+tallyColumn+` = `+tallyColumn+` + 1 WHERE id = $1`, electionID)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	return errgo.Mask(tx.Commit())
+}
+
+// Election implements the Storage interface.
+func (s *sqlStorage) Election(id string) (Election, error) {
+	var fail Election
+	var participants []byte
+	var deadline, closedAt sql.NullTime
+	var election Election
+	row := s.db.QueryRow(`SELECT id, caveat_id, n_approvals_required, message, max_caveats,
+		n_approvals, n_denials, participants, deadline, expired, closed_at FROM elections WHERE id = $1`, id)
+	err := row.Scan(&election.ID, &election.CaveatID, &election.NApprovalsRequired, &election.Message,
+		&election.MaxCaveats, &election.NApprovals, &election.NDenials, &participants, &deadline,
+		&election.Expired, &closedAt)
+	if err == sql.ErrNoRows {
+		return fail, ErrNotFound
+	} else if err != nil {
+		return fail, errgo.Mask(err)
+	}
+	if deadline.Valid {
+		election.Deadline = deadline.Time
+	}
+	if closedAt.Valid {
+		election.ClosedAt = closedAt.Time
+	}
+	if err := json.Unmarshal(participants, &election.Participants); err != nil {
+		return fail, errgo.Mask(err)
+	}
+	return election, nil
+}
+
+// ballotColumns are the columns selected by scanBallot, in order.
+const ballotColumns = `id, election_id, recipient, key_id, message, used, signature, caveats, delivery_status`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows, so scanBallot can
+// be shared between single- and multi-row ballot queries.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBallot scans a row selected with ballotColumns into a Ballot.
+func scanBallot(row rowScanner) (Ballot, error) {
+	var ballot Ballot
+	var signature, caveats sql.NullString
+	var deliveryStatus string
+	err := row.Scan(&ballot.ID, &ballot.Election, &ballot.Recipient, &ballot.KeyID, &ballot.Message,
+		&ballot.Used, &signature, &caveats, &deliveryStatus)
+	if err != nil {
+		return ballot, err
+	}
+	if signature.Valid && signature.String != "" {
+		var sig Signature
+		if err := json.Unmarshal([]byte(signature.String), &sig); err != nil {
+			return ballot, errgo.Mask(err)
+		}
+		ballot.Signature = &sig
+	}
+	if caveats.Valid && caveats.String != "" {
+		if err := json.Unmarshal([]byte(caveats.String), &ballot.Caveats); err != nil {
+			return ballot, errgo.Mask(err)
+		}
+	}
+	ballot.DeliveryStatus = DeliveryStatus(deliveryStatus)
+	return ballot, nil
+}
+
+// Ballot implements the Storage interface.
+func (s *sqlStorage) Ballot(id string) (Ballot, error) {
+	var fail Ballot
+	row := s.db.QueryRow(`SELECT `+ballotColumns+` FROM ballots WHERE id = $1`, id)
+	ballot, err := scanBallot(row)
+	if err == sql.ErrNoRows {
+		return fail, ErrNotFound
+	} else if err != nil {
+		return fail, errgo.Mask(err)
+	}
+	return ballot, nil
+}
+
+// Ballots implements the Storage interface.
+func (s *sqlStorage) Ballots(electionID string) ([]Ballot, error) {
+	rows, err := s.db.Query(`SELECT `+ballotColumns+` FROM ballots WHERE election_id = $1`, electionID)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	defer rows.Close()
+
+	var ballots []Ballot
+	for rows.Next() {
+		ballot, err := scanBallot(rows)
+		if err != nil {
+			return nil, errgo.Mask(err)
+		}
+		ballots = append(ballots, ballot)
+	}
+	return ballots, errgo.Mask(rows.Err())
+}
+
+// SetDeliveryStatus implements the Storage interface.
+func (s *sqlStorage) SetDeliveryStatus(id string, status DeliveryStatus) error {
+	res, err := s.db.Exec(`UPDATE ballots SET delivery_status = $1 WHERE id = $2`, string(status), id)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Caveats implements the Storage interface.
+func (s *sqlStorage) Caveats(electionID string) ([]checkers.Caveat, error) {
+	rows, err := s.db.Query(`SELECT caveats FROM ballots
+		WHERE election_id = $1 AND used = TRUE AND caveats IS NOT NULL`, electionID)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	defer rows.Close()
+
+	var all []checkers.Caveat
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, errgo.Mask(err)
+		}
+		var caveats []checkers.Caveat
+		if err := json.Unmarshal([]byte(raw), &caveats); err != nil {
+			return nil, errgo.Mask(err)
+		}
+		all = append(all, caveats...)
+	}
+	return all, errgo.Mask(rows.Err())
+}
+
+// Watch implements the Storage interface by polling the election's state,
+// since database/sql has no generic change-notification API. The state at
+// the time Watch is called is the baseline; the channel receives the
+// election only when a later poll finds its Result differs from the last
+// one seen, not on every vote. The poll loop exits, closing the channel,
+// once ctx is done or the election no longer exists.
+func (s *sqlStorage) Watch(ctx context.Context, electionID string) <-chan Election {
+	ch := make(chan Election, 1)
+	initial, err := s.Election(electionID)
+	if err != nil {
+		close(ch)
+		return ch
+	}
+	last := initial.Result()
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchPollInterval):
+			}
+			election, err := s.Election(electionID)
+			if err != nil {
+				return
+			}
+			if result := election.Result(); result != last {
+				last = result
+				select {
+				case ch <- election:
+				default:
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// ReapExpired implements the Storage interface.
+func (s *sqlStorage) ReapExpired(now time.Time) error {
+	res, err := s.db.Exec(`UPDATE elections SET expired = TRUE, n_denials = n_denials + 1
+		WHERE expired = FALSE AND deadline IS NOT NULL AND deadline <= $1
+		AND n_approvals < n_approvals_required AND n_denials = 0`, now)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	_, err = res.RowsAffected()
+	return errgo.Mask(err)
+}
+
+// CloseDenied implements the Storage interface.
+func (s *sqlStorage) CloseDenied() error {
+	rows, err := s.db.Query(`SELECT id FROM elections WHERE n_denials > 0 AND closed_at IS NULL`)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return errgo.Mask(err)
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	for _, id := range ids {
+		if err := s.Close(id); err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	return nil
+}
+
+// Close implements the Storage interface. It only records closed_at; the
+// election and its ballots (including their Signatures) stay queryable for
+// audit until a later PurgeClosed call removes them.
+func (s *sqlStorage) Close(id string) error {
+	_, err := s.db.Exec(`UPDATE elections SET closed_at = $1 WHERE id = $2 AND closed_at IS NULL`,
+		time.Now(), id)
+	return errgo.Mask(err)
+}
+
+// PurgeClosed implements the Storage interface.
+func (s *sqlStorage) PurgeClosed(before time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM ballots WHERE election_id IN
+		(SELECT id FROM elections WHERE closed_at IS NOT NULL AND closed_at <= $1)`, before)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if _, err := tx.Exec(`DELETE FROM elections WHERE closed_at IS NOT NULL AND closed_at <= $1`, before); err != nil {
+		return errgo.Mask(err)
+	}
+	return errgo.Mask(tx.Commit())
+}
@@ -0,0 +1,69 @@
+//go:build etcd_integration
+
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+// This suite only builds under -tags etcd_integration, since it needs a
+// live etcd to dial (set ETCD_INTEGRATION_ENDPOINTS to override the
+// default of localhost:2379). Run it with:
+//
+//	go test -tags etcd_integration -run EtcdStorageSuite ./...
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	gc "gopkg.in/check.v1"
+
+	"github.com/cmars/quorum"
+)
+
+// EtcdStorageSuite runs every StorageSuite test against etcdStorage backed
+// by a real etcd cluster, so the vote and Close CAS loops and the
+// PurgeClosed transaction get the same coverage as memStorage.
+type EtcdStorageSuite struct {
+	StorageSuite
+	client *clientv3.Client
+}
+
+var _ = gc.Suite(&EtcdStorageSuite{})
+
+func (s *EtcdStorageSuite) SetUpTest(c *gc.C) {
+	endpoints := []string{"localhost:2379"}
+	if v := os.Getenv("ETCD_INTEGRATION_ENDPOINTS"); v != "" {
+		endpoints = strings.Split(v, ",")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		c.Skip("could not dial etcd: " + err.Error())
+	}
+	s.client = client
+	s.store = quorum.NewEtcdStorage(client, "quorum-test-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+func (s *EtcdStorageSuite) TearDownTest(c *gc.C) {
+	if s.client != nil {
+		c.Assert(s.client.Close(), gc.IsNil)
+	}
+}
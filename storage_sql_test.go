@@ -0,0 +1,50 @@
+/*
+ * Copyright 2015 Casey Marshall
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quorum_test
+
+import (
+	"database/sql"
+
+	gc "gopkg.in/check.v1"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/cmars/quorum"
+)
+
+// SQLStorageSuite runs every StorageSuite test against sqlStorage backed by
+// an in-memory sqlite database, so the vote CAS and schema exercised by
+// NewSQLStorage get the same coverage as memStorage.
+type SQLStorageSuite struct {
+	StorageSuite
+	db *sql.DB
+}
+
+var _ = gc.Suite(&SQLStorageSuite{})
+
+func (s *SQLStorageSuite) SetUpTest(c *gc.C) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	c.Assert(err, gc.IsNil)
+	store, err := quorum.NewSQLStorage(db)
+	c.Assert(err, gc.IsNil)
+	s.db = db
+	s.store = store
+}
+
+func (s *SQLStorageSuite) TearDownTest(c *gc.C) {
+	c.Assert(s.db.Close(), gc.IsNil)
+}
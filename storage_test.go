@@ -17,7 +17,9 @@
 package quorum_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	gc "gopkg.in/check.v1"
 
@@ -39,7 +41,7 @@ func (s *StorageSuite) TestDifferentiateElections(c *gc.C) {
 	election1 := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 1,
-			Participants:       []string{"alice@e1", "bob@e1"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
 			Message:            "election1 message",
 		},
 		ID:       "election1-id",
@@ -59,7 +61,7 @@ func (s *StorageSuite) TestDifferentiateElections(c *gc.C) {
 	election2 := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 2,
-			Participants:       []string{"carol@e2", "dave@e2"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "carol-key", Recipient: "carol@e2"}, {KeyID: "dave-key", Recipient: "dave@e2"}},
 			Message:            "election2 message",
 		},
 		ID:       "election2-id",
@@ -89,13 +91,13 @@ func (s *StorageSuite) TestInvalidElection(c *gc.C) {
 	election := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 3,
-			Participants:       []string{"alice@e1", "bob@e1"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
 			Message:            "election message",
 		},
 		ID:       "election-id",
 		CaveatID: "election-caveatid",
 	}
-	err := election.Validate()
+	err := election.Validate(nil)
 	c.Assert(err, gc.ErrorMatches, "2 recipients will never satisfy 3 approver requirement")
 }
 
@@ -103,7 +105,7 @@ func (s *StorageSuite) TestAccept(c *gc.C) {
 	election := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 2,
-			Participants:       []string{"alice@e1", "bob@e1"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
 			Message:            "election message",
 		},
 		ID:       "election-id",
@@ -121,23 +123,23 @@ func (s *StorageSuite) TestAccept(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Approve("alice-ballot")
+	err = s.store.Approve("alice-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Approve("alice-ballot")
+	err = s.store.Approve("alice-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Approve("mallory-ballot")
+	err = s.store.Approve("mallory-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Deny("mallory-ballot")
+	err = s.store.Deny("mallory-ballot", quorum.Signature{})
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Approve("bob-ballot")
+	err = s.store.Approve("bob-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionApproved)
 }
@@ -152,7 +154,7 @@ func (s *StorageSuite) TestDeny(c *gc.C) {
 	election := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 2,
-			Participants:       []string{"alice@e1", "bob@e1"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
 			Message:            "election message",
 		},
 		ID:       "election-id",
@@ -170,24 +172,24 @@ func (s *StorageSuite) TestDeny(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionPending)
 
-	err = s.store.Deny("alice-ballot")
+	err = s.store.Deny("alice-ballot", quorum.Signature{})
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionDenied)
 
-	err = s.store.Approve("alice-ballot")
+	err = s.store.Approve("alice-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionDenied)
 
-	err = s.store.Approve("mallory-ballot")
+	err = s.store.Approve("mallory-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionDenied)
 
-	err = s.store.Deny("mallory-ballot")
+	err = s.store.Deny("mallory-ballot", quorum.Signature{})
 	c.Assert(err, gc.ErrorMatches, "not found")
 	s.assertResult(c, "election-id", quorum.ElectionDenied)
 
 	// Bob can approve, but it won't change the outcome.
-	err = s.store.Approve("bob-ballot")
+	err = s.store.Approve("bob-ballot", quorum.Signature{}, nil)
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionDenied)
 }
@@ -196,7 +198,7 @@ func (s *StorageSuite) TestNoApprovalsRequired(c *gc.C) {
 	election := quorum.Election{
 		Policy: quorum.Policy{
 			NApprovalsRequired: 0,
-			Participants:       []string{"alice@e1", "bob@e1"},
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
 			Message:            "election message",
 		},
 		ID:       "election-id",
@@ -214,3 +216,210 @@ func (s *StorageSuite) TestNoApprovalsRequired(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	s.assertResult(c, "election-id", quorum.ElectionApproved)
 }
+
+func (s *StorageSuite) TestReapExpired(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 2,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
+			Message:            "election message",
+			Deadline:           time.Now().Add(time.Minute),
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:        "alice-ballot",
+		Election:  "election-id",
+		Recipient: "alice@e1",
+	}})
+	c.Assert(err, gc.IsNil)
+	s.assertResult(c, "election-id", quorum.ElectionPending)
+
+	err = s.store.ReapExpired(time.Now())
+	c.Assert(err, gc.IsNil)
+	s.assertResult(c, "election-id", quorum.ElectionPending)
+
+	err = s.store.ReapExpired(time.Now().Add(2 * time.Minute))
+	c.Assert(err, gc.IsNil)
+	s.assertResult(c, "election-id", quorum.ElectionDenied)
+
+	e, err := s.store.Election("election-id")
+	c.Assert(err, gc.IsNil)
+	c.Assert(e.Expired, gc.Equals, true)
+}
+
+func (s *StorageSuite) TestWatch(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 1,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+			Message:            "election message",
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:        "alice-ballot",
+		Election:  "election-id",
+		Recipient: "alice@e1",
+	}})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := s.store.Watch(ctx, "election-id")
+
+	err = s.store.Approve("alice-ballot", quorum.Signature{}, nil)
+	c.Assert(err, gc.IsNil)
+
+	select {
+	case updated, ok := <-ch:
+		c.Assert(ok, gc.Equals, true)
+		c.Assert(updated.Result(), gc.Equals, quorum.ElectionApproved)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for watch notification")
+	}
+}
+
+func (s *StorageSuite) TestWatchCancel(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 1,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+			Message:            "election message",
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:        "alice-ballot",
+		Election:  "election-id",
+		Recipient: "alice@e1",
+	}})
+	c.Assert(err, gc.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := s.store.Watch(ctx, "election-id")
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		c.Assert(ok, gc.Equals, false)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for watch to close after cancel")
+	}
+}
+
+func (s *StorageSuite) TestCloseDenied(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 2,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}, {KeyID: "bob-key", Recipient: "bob@e1"}},
+			Message:            "election message",
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:        "alice-ballot",
+		Election:  "election-id",
+		Recipient: "alice@e1",
+	}})
+	c.Assert(err, gc.IsNil)
+
+	err = s.store.Deny("alice-ballot", quorum.Signature{})
+	c.Assert(err, gc.IsNil)
+	s.assertResult(c, "election-id", quorum.ElectionDenied)
+
+	err = s.store.CloseDenied()
+	c.Assert(err, gc.IsNil)
+
+	e, err := s.store.Election("election-id")
+	c.Assert(err, gc.IsNil)
+	c.Assert(e.ClosedAt.IsZero(), gc.Equals, false)
+}
+
+func (s *StorageSuite) TestDeliveryStatus(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 1,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+			Message:            "election message",
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:             "alice-ballot",
+		Election:       "election-id",
+		Recipient:      "alice@e1",
+		DeliveryStatus: quorum.DeliveryPending,
+	}})
+	c.Assert(err, gc.IsNil)
+
+	err = s.store.SetDeliveryStatus("alice-ballot", quorum.DeliveryDelivered)
+	c.Assert(err, gc.IsNil)
+
+	ballot, err := s.store.Ballot("alice-ballot")
+	c.Assert(err, gc.IsNil)
+	c.Assert(ballot.DeliveryStatus, gc.Equals, quorum.DeliveryDelivered)
+
+	ballots, err := s.store.Ballots("election-id")
+	c.Assert(err, gc.IsNil)
+	c.Assert(ballots, gc.HasLen, 1)
+	c.Assert(ballots[0].DeliveryStatus, gc.Equals, quorum.DeliveryDelivered)
+
+	err = s.store.SetDeliveryStatus("mallory-ballot", quorum.DeliveryDelivered)
+	c.Assert(err, gc.ErrorMatches, "not found")
+}
+
+// TestCloseRetainsBallotsUntilPurged checks that Close marks an election
+// closed without deleting it or its ballots, so an approver's Signature
+// survives for auditing; PurgeClosed is the only thing that removes them,
+// and only once the retention cutoff has passed.
+func (s *StorageSuite) TestCloseRetainsBallotsUntilPurged(c *gc.C) {
+	election := quorum.Election{
+		Policy: quorum.Policy{
+			NApprovalsRequired: 1,
+			Participants:       []quorum.ParticipantRef{{KeyID: "alice-key", Recipient: "alice@e1"}},
+			Message:            "election message",
+		},
+		ID:       "election-id",
+		CaveatID: "election-caveatid",
+	}
+	err := s.store.Add(election, []quorum.Ballot{{
+		ID:        "alice-ballot",
+		Election:  "election-id",
+		Recipient: "alice@e1",
+	}})
+	c.Assert(err, gc.IsNil)
+
+	err = s.store.Approve("alice-ballot", quorum.Signature{}, nil)
+	c.Assert(err, gc.IsNil)
+
+	err = s.store.Close("election-id")
+	c.Assert(err, gc.IsNil)
+
+	e, err := s.store.Election("election-id")
+	c.Assert(err, gc.IsNil)
+	c.Assert(e.ClosedAt.IsZero(), gc.Equals, false)
+
+	ballot, err := s.store.Ballot("alice-ballot")
+	c.Assert(err, gc.IsNil)
+	c.Assert(ballot.Used, gc.Equals, true)
+
+	// Purging before the election was closed leaves it untouched.
+	err = s.store.PurgeClosed(e.ClosedAt.Add(-time.Minute))
+	c.Assert(err, gc.IsNil)
+	_, err = s.store.Election("election-id")
+	c.Assert(err, gc.IsNil)
+
+	// Purging after it was closed removes the election and its ballots.
+	err = s.store.PurgeClosed(e.ClosedAt.Add(time.Minute))
+	c.Assert(err, gc.IsNil)
+	_, err = s.store.Election("election-id")
+	c.Assert(err, gc.ErrorMatches, "not found")
+	_, err = s.store.Ballot("alice-ballot")
+	c.Assert(err, gc.ErrorMatches, "not found")
+}